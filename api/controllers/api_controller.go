@@ -2,14 +2,13 @@ package controllers
 
 import (
 	"context"
-	"crypto/hmac"
-	"crypto/sha256"
-	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"net/http"
-	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/Sumit189/letItGo/common/models"
@@ -18,8 +17,15 @@ import (
 	"github.com/Sumit189/letItGo/consumer/services"
 )
 
+const (
+	maxJitterSeconds    = 3600
+	defaultPreviewCount = 5
+	maxPreviewCount     = 50
+	maxTriggers         = 20
+)
+
 func ScheduleHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
-	scheduler, err := parseAndValidatePayload(ctx, w, r)
+	scheduler, interpretation, err := parseAndValidatePayload(ctx, w, r)
 	if err != nil {
 		return
 	}
@@ -30,7 +36,8 @@ func ScheduleHandler(ctx context.Context, w http.ResponseWriter, r *http.Request
 
 	scheduled, err := services.Schedule(ctx, *scheduler)
 	if err != nil {
-		http.Error(w, "Error scheduling webhook: "+err.Error(), http.StatusInternalServerError)
+		// A first run beyond end_at is the client's mistake, not ours
+		writeScheduleError(w, "Error scheduling webhook: ", err)
 		return
 	}
 
@@ -39,69 +46,342 @@ func ScheduleHandler(ctx context.Context, w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	timezone := scheduled.Timezone
+	if timezone == "" {
+		timezone = "UTC"
+	}
+
 	w.WriteHeader(http.StatusCreated)
 	timeStr := ""
+	localTimeStr := ""
 	if scheduled.NextRunTime != nil {
-		timeStr = scheduled.NextRunTime.Format(time.RFC3339)
+		timeStr = scheduled.NextRunTime.UTC().Format(time.RFC3339)
+		if loc, err := repository.LoadTimezone(scheduled.Timezone); err == nil {
+			localTimeStr = scheduled.NextRunTime.In(loc).Format(time.RFC3339)
+		}
+	}
+	response := map[string]interface{}{"message": "Task scheduled", "time": timeStr, "local_time": localTimeStr, "timezone": timezone, "cron": scheduled.CronExpression, "rrule": scheduled.RRule, "id": scheduled.ID}
+	if len(scheduled.Triggers) > 0 {
+		response["triggers"] = scheduled.Triggers
 	}
-	json.NewEncoder(w).Encode(map[string]string{"message": "Task scheduled", "time": timeStr, "cron": scheduled.CronExpression, "id": scheduled.ID})
+	if interpretation != nil {
+		// Tells whether time_as_text was understood offline or by the LLM
+		response["source"] = interpretation.Source
+	}
+	json.NewEncoder(w).Encode(response)
 }
 
-func parseAndValidatePayload(ctx context.Context, w http.ResponseWriter, r *http.Request) (*models.Scheduler, error) {
+// PreviewScheduleHandler returns the next runs of a schedule without storing
+// it. It accepts the same timing fields as ScheduleHandler.
+func PreviewScheduleHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
 	var tempPayload map[string]interface{}
 	if err := json.NewDecoder(r.Body).Decode(&tempPayload); err != nil {
 		http.Error(w, "Invalid payload", http.StatusBadRequest)
-		return nil, err
+		return
+	}
+
+	count, err := parseOptionalNonNegativeInt(tempPayload, "count")
+	if err == nil && count > maxPreviewCount {
+		err = fmt.Errorf("count must not exceed %d", maxPreviewCount)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if count == 0 {
+		count = defaultPreviewCount
+	}
+
+	// webhook_url and method_type are optional here; they only seed H fields
+	scheduler := models.NewScheduler()
+	scheduler.WebhookURL, _ = tempPayload["webhook_url"].(string)
+	scheduler.MethodType, _ = tempPayload["method_type"].(string)
+
+	interpretation, err := parseTiming(ctx, tempPayload, scheduler)
+	if err != nil {
+		writeTimingError(w, err)
+		return
+	}
+
+	runs, err := repository.PreviewRuns(ctx, *scheduler, count)
+	if err != nil {
+		http.Error(w, "Error previewing schedule: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	timezone := scheduler.Timezone
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	loc, err := repository.LoadTimezone(scheduler.Timezone)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	occurrences := make([]map[string]string, 0, len(runs))
+	for _, run := range runs {
+		occurrences = append(occurrences, map[string]string{
+			"time":       run.UTC().Format(time.RFC3339),
+			"local_time": run.In(loc).Format(time.RFC3339),
+		})
+	}
+
+	response := map[string]interface{}{
+		"timezone":    timezone,
+		"cron":        scheduler.CronExpression,
+		"rrule":       scheduler.RRule,
+		"occurrences": occurrences,
+	}
+	if len(scheduler.Triggers) > 0 {
+		response["triggers"] = scheduler.Triggers
+	}
+	if interpretation != nil {
+		response["interpretation"] = interpretation
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func parseAndValidatePayload(ctx context.Context, w http.ResponseWriter, r *http.Request) (*models.Scheduler, *textInterpretation, error) {
+	var tempPayload map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&tempPayload); err != nil {
+		http.Error(w, "Invalid payload", http.StatusBadRequest)
+		return nil, nil, err
 	}
 
 	scheduler := models.NewScheduler()
 	if err := utils.ValidateAndAssignStringField(ctx, tempPayload, "webhook_url", &scheduler.WebhookURL, w); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	if err := utils.ValidateAndAssignStringField(ctx, tempPayload, "method_type", &scheduler.MethodType, w); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// check if webhook_url and method_type are valid
 	IsVerifiedWebhook := repository.IsVerifiedWebhook(ctx, scheduler.WebhookURL, scheduler.MethodType)
 	if !IsVerifiedWebhook {
 		http.Error(w, "Webhook is not verified", http.StatusBadRequest)
-		return nil, errors.New("Webhook is not verified")
+		return nil, nil, errors.New("Webhook is not verified")
 	}
 
 	payloadBytes, err := json.Marshal(tempPayload["payload"])
 	if err != nil {
 		http.Error(w, "Failed to encode payload", http.StatusInternalServerError)
-		return nil, err
+		return nil, nil, err
 	}
 	scheduler.Payload = string(payloadBytes)
 
+	interpretation, err := parseTiming(ctx, tempPayload, scheduler)
+	if err != nil {
+		writeTimingError(w, err)
+		return nil, nil, err
+	}
+
+	if err := parseMisfireSettings(tempPayload, scheduler); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, nil, err
+	}
+
+	jitterSeconds, err := parseOptionalNonNegativeInt(tempPayload, "jitter_seconds")
+	if err == nil && jitterSeconds > maxJitterSeconds {
+		err = fmt.Errorf("jitter_seconds must not exceed %d", maxJitterSeconds)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, nil, err
+	}
+	scheduler.JitterSeconds = jitterSeconds
+
+	priority, err := parsePriority(tempPayload)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, nil, err
+	}
+	scheduler.Priority = priority
+
+	if maxLateness, ok := tempPayload["max_lateness"]; ok && maxLateness != nil {
+		now := time.Now()
+		deadline, err := resolveDelay(maxLateness, now)
+		if err != nil {
+			err = errors.New("max_lateness must be a positive number of seconds or an ISO 8601 duration")
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return nil, nil, err
+		}
+		scheduler.MaxLatenessSeconds = int(math.Ceil(deadline.Sub(now).Seconds()))
+	}
+
+	return scheduler, interpretation, nil
+}
+
+// parsePriority reads priority, given as low, normal, high or critical or as
+// the matching level from -1 to 2.
+func parsePriority(tempPayload map[string]interface{}) (int, error) {
+	switch value := tempPayload["priority"].(type) {
+	case nil:
+		return models.PriorityNormal, nil
+	case string:
+		if level, ok := models.PriorityLevels[strings.ToLower(value)]; ok {
+			return level, nil
+		}
+	case float64:
+		if value == math.Trunc(value) && value >= models.PriorityLow && value <= models.PriorityCritical {
+			return int(value), nil
+		}
+	}
+	return 0, errors.New("priority must be one of low, normal, high or critical")
+}
+
+// parseHashKey reads hash_key, an optional string mixed into the hash that H
+// tokens in cron fields resolve from.
+func parseHashKey(tempPayload map[string]interface{}) (string, error) {
+	switch value := tempPayload["hash_key"].(type) {
+	case nil:
+		return "", nil
+	case string:
+		return value, nil
+	}
+	return "", errors.New("hash_key must be a string")
+}
+
+// TextCacheStatsHandler reports how often time_as_text was answered from the
+// cache instead of the LLM.
+func TextCacheStatsHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	stats, err := repository.GetTextCacheStats(ctx)
+	if err != nil {
+		http.Error(w, "Error fetching text cache stats: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// writeTimingError responds to a timing error. A time_as_text that could not
+// be resolved gets a JSON body explaining why, so the client can rephrase it.
+func writeTimingError(w http.ResponseWriter, err error) {
+	var textErr *repository.TextScheduleError
+	if !errors.As(err, &textErr) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":  "time_as_text could not be interpreted",
+		"detail": textErr,
+	})
+}
+
+// textInterpretation records how time_as_text was understood, and whether
+// the built-in rules or the LLM resolved it. A compound phrase is described
+// by its triggers.
+type textInterpretation struct {
+	Text string `json:"text"`
+	textResult
+	Triggers []textResult `json:"triggers,omitempty"`
+	Source   string       `json:"source"`
+	Timezone string       `json:"timezone"`
+	Locale   string       `json:"locale,omitempty"`
+}
+
+// textResult is a time or cron expression time_as_text resolved to. Times are
+// given in UTC and in the schedule's timezone.
+type textResult struct {
+	Result    string `json:"result,omitempty"`
+	LocalTime string `json:"local_time,omitempty"`
+	IsCron    bool   `json:"is_cron"`
+}
+
+func newTextResult(parsed repository.TextSchedule, loc *time.Location) textResult {
+	if parsed.IsCron {
+		return textResult{Result: parsed.Value, IsCron: true}
+	}
+	// ParseTextSchedule validated the time already
+	runTime, _ := time.Parse(time.RFC3339, parsed.Value)
+	return textResult{
+		Result:    runTime.UTC().Format(time.RFC3339),
+		LocalTime: runTime.In(loc).Format(time.RFC3339),
+	}
+}
+
+// parseTiming reads the fields that decide when a schedule runs: timezone,
+// time_as_text, schedule_time, delay, cron_expression, rrule, the recurrence
+// bounds and the business calendar. The returned interpretation is nil unless
+// time_as_text was given.
+func parseTiming(ctx context.Context, tempPayload map[string]interface{}, scheduler *models.Scheduler) (*textInterpretation, error) {
+	hashKey, err := parseHashKey(tempPayload)
+	if err != nil {
+		return nil, err
+	}
+	scheduler.HashKey = hashKey
+
+	if timezone, ok := tempPayload["timezone"].(string); ok {
+		if err := repository.ValidateTimezone(timezone); err != nil {
+			return nil, err
+		}
+		scheduler.Timezone = timezone
+	}
+
+	var interpretation *textInterpretation
 	if timeAsText, ok := tempPayload["time_as_text"].(string); ok {
-		timeStringOrCronExp, isCron, err := repository.TextToTimeOrCronExpression(ctx, timeAsText)
-		if err != nil || timeStringOrCronExp == "" {
-			http.Error(w, "Failed to convert text to time string or cron expression", http.StatusBadRequest)
+		locale, _ := tempPayload["locale"].(string)
+		if locale != "" {
+			if _, err := repository.ParseLocale(locale); err != nil {
+				return nil, fmt.Errorf("invalid locale: %s", locale)
+			}
+		}
+		loc, err := repository.LoadTimezone(scheduler.Timezone)
+		if err != nil {
+			return nil, err
+		}
+
+		// Phrases like "tomorrow at 9am" are read in the schedule's timezone
+		parsed, err := repository.ParseTextSchedule(ctx, timeAsText, locale, time.Now().In(loc))
+		var textErr *repository.TextScheduleError
+		if errors.As(err, &textErr) {
 			return nil, err
 		}
+		if err != nil {
+			return nil, errors.New("Failed to convert text to time string or cron expression")
+		}
+		interpretation = &textInterpretation{
+			Text:     timeAsText,
+			Source:   parsed.Source,
+			Timezone: loc.String(),
+			Locale:   locale,
+		}
 
-		if isCron {
-			tempPayload["cron_expression"] = timeStringOrCronExp
-		} else {
-			tempPayload["schedule_time"] = timeStringOrCronExp
+		switch {
+		case len(parsed.Triggers) > 0:
+			// A compound phrase becomes one trigger per rule
+			triggers := make([]interface{}, 0, len(parsed.Triggers))
+			for _, trigger := range parsed.Triggers {
+				field := "schedule_time"
+				if trigger.IsCron {
+					field = "cron_expression"
+				}
+				triggers = append(triggers, map[string]interface{}{field: trigger.Value})
+				interpretation.Triggers = append(interpretation.Triggers, newTextResult(trigger, loc))
+			}
+			tempPayload["triggers"] = triggers
+		case parsed.IsCron:
+			interpretation.textResult = newTextResult(parsed, loc)
+			tempPayload["cron_expression"] = parsed.Value
+		default:
+			interpretation.textResult = newTextResult(parsed, loc)
+			tempPayload["schedule_time"] = parsed.Value
 		}
 	}
 
 	if scheduleTimeStr, ok := tempPayload["schedule_time"].(string); ok {
 		scheduleTime, err := time.Parse(time.RFC3339, scheduleTimeStr)
 		if err != nil {
-			http.Error(w, "Invalid schedule_time format", http.StatusBadRequest)
-			return nil, err
+			return nil, errors.New("Invalid schedule_time format")
 		}
+		scheduleTime = scheduleTime.UTC()
 
 		// error out if the schedule time is in the past
 		if scheduleTime.Before(time.Now().UTC()) {
-			err := errors.New("schedule_time must be in the future")
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return nil, err
+			return nil, errors.New("schedule_time must be in the future")
 		}
 		scheduler.ScheduleTime = &scheduleTime
 	}
@@ -110,100 +390,268 @@ func parseAndValidatePayload(ctx context.Context, w http.ResponseWriter, r *http
 		scheduler.CronExpression = cronExpr
 	}
 
-	if scheduler.ScheduleTime == nil && scheduler.CronExpression == "" {
-		err := errors.New("either schedule_time or cron_expression must be provided")
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return nil, err
+	if delay, ok := tempPayload["delay"]; ok && delay != nil {
+		if scheduler.ScheduleTime != nil || scheduler.CronExpression != "" {
+			return nil, errors.New("delay cannot be combined with schedule_time or cron_expression")
+		}
+		scheduleTime, err := resolveDelay(delay, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		scheduler.ScheduleTime = &scheduleTime
 	}
 
-	if scheduler.ScheduleTime != nil && scheduler.ScheduleTime.Location() != time.UTC {
-		err := errors.New("ScheduleTime must be in UTC")
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if rrule, ok := tempPayload["rrule"].(string); ok && rrule != "" {
+		if scheduler.ScheduleTime != nil || scheduler.CronExpression != "" {
+			return nil, errors.New("rrule cannot be combined with schedule_time, delay or cron_expression")
+		}
+		normalized, err := repository.NormalizeRRule(rrule, scheduler.Timezone, time.Now())
+		if err != nil {
+			return nil, errors.New("Invalid rrule: " + err.Error())
+		}
+		scheduler.RRule = normalized
+	}
+
+	if err := parseTriggers(tempPayload, scheduler); err != nil {
 		return nil, err
 	}
 
+	if scheduler.ScheduleTime == nil && !scheduler.IsRecurring() {
+		return nil, errors.New("either schedule_time, delay, cron_expression, rrule or triggers must be provided")
+	}
+
 	if cronExpr := scheduler.CronExpression; cronExpr != "" {
 		if err := repository.ValidateCron(cronExpr); err != nil {
-			http.Error(w, "Invalid cron expression: "+err.Error(), http.StatusBadRequest)
-			return nil, err
+			return nil, errors.New("Invalid cron expression: " + err.Error())
 		}
 	}
 
-	return scheduler, nil
+	if err := parseRecurrenceBounds(tempPayload, scheduler); err != nil {
+		return nil, err
+	}
+	if err := parseCalendar(ctx, tempPayload, scheduler); err != nil {
+		return nil, err
+	}
+	return interpretation, nil
 }
 
-func VerifyWebhookHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
-	var payload map[string]interface{}
+// parseTriggers reads triggers, the rules of a schedule that runs whenever
+// any of them is due. Each trigger has exactly one of schedule_time,
+// cron_expression or rrule.
+func parseTriggers(tempPayload map[string]interface{}, scheduler *models.Scheduler) error {
+	rawTriggers, ok := tempPayload["triggers"]
+	if !ok || rawTriggers == nil {
+		return nil
+	}
+	list, ok := rawTriggers.([]interface{})
+	if !ok || len(list) == 0 {
+		return errors.New("triggers must be a non-empty list")
+	}
+	if len(list) > maxTriggers {
+		return fmt.Errorf("at most %d triggers are allowed", maxTriggers)
+	}
+	if scheduler.ScheduleTime != nil || scheduler.CronExpression != "" || scheduler.RRule != "" {
+		return errors.New("triggers cannot be combined with schedule_time, delay, cron_expression or rrule")
+	}
+
+	triggers := make([]models.Trigger, 0, len(list))
+	for i, rawTrigger := range list {
+		fields, ok := rawTrigger.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("trigger %d must be an object", i)
+		}
 
-	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
-		http.Error(w, "Invalid payload", http.StatusBadRequest)
-		return
+		var trigger models.Trigger
+		set := 0
+		if value, ok := fields["schedule_time"].(string); ok {
+			scheduleTime, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return fmt.Errorf("trigger %d: invalid schedule_time format", i)
+			}
+			if !scheduleTime.After(time.Now()) {
+				return fmt.Errorf("trigger %d: schedule_time must be in the future", i)
+			}
+			scheduleTime = scheduleTime.UTC()
+			trigger.ScheduleTime = &scheduleTime
+			set++
+		}
+		if value, ok := fields["cron_expression"].(string); ok {
+			if err := repository.ValidateCron(value); err != nil {
+				return fmt.Errorf("trigger %d: invalid cron expression: %v", i, err)
+			}
+			trigger.CronExpression = value
+			set++
+		}
+		if value, ok := fields["rrule"].(string); ok {
+			normalized, err := repository.NormalizeRRule(value, scheduler.Timezone, time.Now())
+			if err != nil {
+				return fmt.Errorf("trigger %d: invalid rrule: %v", i, err)
+			}
+			trigger.RRule = normalized
+			set++
+		}
+		if set != 1 {
+			return fmt.Errorf("trigger %d must have exactly one of schedule_time, cron_expression or rrule", i)
+		}
+		triggers = append(triggers, trigger)
 	}
+	scheduler.Triggers = triggers
 
-	fmt.Println("Payload: ", payload)
+	// Each trigger passed on its own; together they may still fire too often
+	if err := repository.ValidateTriggers(*scheduler); err != nil {
+		return errors.New("Invalid triggers: " + err.Error())
+	}
+	return nil
+}
 
-	if len(payload) == 0 {
-		http.Error(w, "Empty payload", http.StatusBadRequest)
-		return
+// parseRecurrenceBounds reads start_at, end_at and max_runs, which only apply
+// to recurring schedules.
+func parseRecurrenceBounds(tempPayload map[string]interface{}, scheduler *models.Scheduler) error {
+	startAt, err := parseOptionalTime(tempPayload, "start_at")
+	if err != nil {
+		return err
+	}
+	endAt, err := parseOptionalTime(tempPayload, "end_at")
+	if err != nil {
+		return err
 	}
 
-	webhookURL := payload["webhook_url"].(string)
-	if webhookURL == "" {
-		http.Error(w, "Missing webhook URL", http.StatusBadRequest)
-		return
+	maxRuns, err := parseOptionalNonNegativeInt(tempPayload, "max_runs")
+	if err != nil {
+		return err
 	}
+	scheduler.MaxRuns = maxRuns
 
-	methodType := payload["method_type"].(string)
-	if methodType == "" {
-		http.Error(w, "Missing method type: [\"GET\", \"POST\"]", http.StatusBadRequest)
-		return
+	if (startAt != nil || endAt != nil || scheduler.MaxRuns > 0) && !scheduler.IsRecurring() {
+		return errors.New("start_at, end_at and max_runs apply to recurring schedules only")
+	}
+	if endAt != nil && !endAt.After(time.Now()) {
+		return errors.New("end_at must be in the future")
+	}
+	if startAt != nil && endAt != nil && !endAt.After(*startAt) {
+		return errors.New("end_at must be after start_at")
 	}
 
-	// Check if the webhook URL is already verified
-	isVerified := repository.IsVerifiedWebhook(ctx, webhookURL, methodType)
-	if isVerified {
-		http.Error(w, "Webhook already verified", http.StatusBadRequest)
-		return
+	scheduler.StartAt = startAt
+	scheduler.EndAt = endAt
+	return nil
+}
+
+// parseCalendar reads calendar and calendar_rule. One-time schedules are
+// adjusted to the calendar right away; recurring ones on every run.
+func parseCalendar(ctx context.Context, tempPayload map[string]interface{}, scheduler *models.Scheduler) error {
+	calendarName, ok := tempPayload["calendar"].(string)
+	if !ok || calendarName == "" {
+		if _, hasRule := tempPayload["calendar_rule"]; hasRule {
+			return errors.New("calendar_rule requires a calendar")
+		}
+		return nil
 	}
 
-	secretKey := os.Getenv("WEBHOOK_SECRET_KEY")
+	rule := models.CalendarSkip
+	if value, ok := tempPayload["calendar_rule"].(string); ok {
+		rule = value
+	}
+	if err := repository.ValidCalendarRule(rule); err != nil {
+		return err
+	}
+	if _, err := repository.FindCalendar(ctx, calendarName); err != nil {
+		return err
+	}
+	scheduler.Calendar = calendarName
+	scheduler.CalendarRule = rule
 
-	// Generate the signature based on the webhook URL
-	secureSignature := GenerateSignature(webhookURL, secretKey)
+	if scheduler.ScheduleTime != nil {
+		adjusted, err := repository.AdjustToCalendar(ctx, calendarName, rule, *scheduler.ScheduleTime, scheduler.Timezone)
+		if err != nil {
+			return err
+		}
+		if adjusted.Before(time.Now()) {
+			return errors.New("schedule_time moved to the previous business day is in the past")
+		}
+		scheduler.ScheduleTime = &adjusted
+	}
+	return nil
+}
 
-	// Create the HTTP request
-	req, err := http.NewRequestWithContext(ctx, "POST", webhookURL, nil)
-	if err != nil {
-		return
+// parseMisfireSettings reads concurrency_policy, misfire_policy and
+// misfire_tolerance_seconds.
+func parseMisfireSettings(tempPayload map[string]interface{}, scheduler *models.Scheduler) error {
+	if policy, ok := tempPayload["concurrency_policy"].(string); ok {
+		if err := repository.ValidConcurrencyPolicy(policy, scheduler.IsRecurring()); err != nil {
+			return err
+		}
+		scheduler.ConcurrencyPolicy = policy
 	}
-	req.Header.Set("Content-Type", "application/json")
 
-	// Send the request
-	client := &http.Client{Timeout: 3 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return
+	if policy, ok := tempPayload["misfire_policy"].(string); ok {
+		if err := repository.ValidMisfirePolicy(policy, scheduler.IsRecurring()); err != nil {
+			return err
+		}
+		scheduler.MisfirePolicy = policy
 	}
-	defer resp.Body.Close()
 
-	// check headers for the signature
-	// Compare the signatures
-	signature := resp.Header.Get("X-Webhook-Signature")
-	if secureSignature != signature {
-		http.Error(w, "Webhook verification failed", http.StatusBadRequest)
-		return
+	tolerance, err := parseOptionalNonNegativeInt(tempPayload, "misfire_tolerance_seconds")
+	if err != nil {
+		return err
 	}
+	scheduler.MisfireToleranceSeconds = tolerance
+	return nil
+}
 
-	repository.AddVerifiedWebhook(ctx, webhookURL, methodType)
+// resolveDelay turns delay, given as seconds or an ISO 8601 duration such as
+// PT15M, into a UTC run time measured from now.
+func resolveDelay(delay interface{}, now time.Time) (time.Time, error) {
+	var scheduleTime time.Time
+	switch value := delay.(type) {
+	case float64:
+		if math.IsNaN(value) || value <= 0 || value > float64(math.MaxInt64/int64(time.Second)) {
+			return time.Time{}, errors.New("delay must be a positive number of seconds")
+		}
+		scheduleTime = now.Add(time.Duration(value * float64(time.Second)))
+	case string:
+		if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+			return resolveDelay(seconds, now)
+		}
+		resolved, err := utils.AddISODuration(now, value)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if !resolved.After(now) {
+			return time.Time{}, errors.New("delay must be positive")
+		}
+		scheduleTime = resolved
+	default:
+		return time.Time{}, errors.New("delay must be a number of seconds or an ISO 8601 duration")
+	}
+	return scheduleTime.UTC(), nil
+}
 
-	// Respond with a success message after one-time verification
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("Webhook successfully verified"))
+func parseOptionalNonNegativeInt(tempPayload map[string]interface{}, fieldName string) (int, error) {
+	value, ok := tempPayload[fieldName]
+	if !ok || value == nil {
+		return 0, nil
+	}
+	number, ok := value.(float64)
+	if !ok || number < 0 || number != math.Trunc(number) {
+		return 0, errors.New(fieldName + " must be a non-negative integer")
+	}
+	return int(number), nil
 }
 
-func GenerateSignature(url, secretKey string) string {
-	// Create a secure signature using HMAC with SHA256
-	mac := hmac.New(sha256.New, []byte(secretKey))
-	mac.Write([]byte(url))
-	return hex.EncodeToString(mac.Sum(nil))
+func parseOptionalTime(tempPayload map[string]interface{}, fieldName string) (*time.Time, error) {
+	value, ok := tempPayload[fieldName]
+	if !ok || value == nil {
+		return nil, nil
+	}
+	timeStr, ok := value.(string)
+	if !ok {
+		return nil, errors.New("invalid " + fieldName)
+	}
+	parsed, err := time.Parse(time.RFC3339, timeStr)
+	if err != nil {
+		return nil, errors.New("invalid " + fieldName + " format")
+	}
+	parsed = parsed.UTC()
+	return &parsed, nil
 }
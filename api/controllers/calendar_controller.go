@@ -0,0 +1,106 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/Sumit189/letItGo/common/models"
+	"github.com/Sumit189/letItGo/common/repository"
+	"github.com/gorilla/mux"
+)
+
+// SaveCalendarHandler creates or replaces a business calendar from a list of
+// dates and/or an imported ICS file.
+func SaveCalendarHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		Name  string   `json:"name"`
+		Dates []string `json:"dates"`
+		ICS   string   `json:"ics"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	name := strings.TrimSpace(payload.Name)
+	if name == "" {
+		http.Error(w, "Missing calendar name", http.StatusBadRequest)
+		return
+	}
+
+	dates := payload.Dates
+	if payload.ICS != "" {
+		icsDates, err := repository.ParseICSDates(payload.ICS)
+		if err != nil {
+			http.Error(w, "Invalid ICS data: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		dates = append(dates, icsDates...)
+	}
+
+	normalized, err := repository.NormalizeCalendarDates(dates)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(normalized) == 0 {
+		http.Error(w, "Either dates or ics must be provided", http.StatusBadRequest)
+		return
+	}
+
+	calendar := models.NewCalendar()
+	calendar.Name = name
+	calendar.Dates = normalized
+	saved, err := repository.SaveCalendar(ctx, *calendar)
+	if err != nil {
+		http.Error(w, "Error saving calendar: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(saved)
+}
+
+func ListCalendarsHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	calendars, err := repository.ListCalendars(ctx)
+	if err != nil {
+		http.Error(w, "Error listing calendars: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(calendars)
+}
+
+func GetCalendarHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	calendar, err := repository.FindCalendar(ctx, mux.Vars(r)["name"])
+	if err != nil {
+		if errors.Is(err, repository.ErrCalendarNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Error fetching calendar: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(calendar)
+}
+
+func DeleteCalendarHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	err := repository.DeleteCalendar(ctx, mux.Vars(r)["name"])
+	if err != nil {
+		if errors.Is(err, repository.ErrCalendarNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
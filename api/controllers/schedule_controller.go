@@ -0,0 +1,217 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Sumit189/letItGo/common/models"
+	"github.com/Sumit189/letItGo/common/repository"
+	"github.com/Sumit189/letItGo/common/utils"
+	"github.com/gorilla/mux"
+)
+
+const (
+	defaultExecutionsLimit = 50
+	maxExecutionsLimit     = 500
+)
+
+// timingFields replace the timing of a schedule as a whole when any of them is
+// present in an update.
+var timingFields = []string{"schedule_time", "delay", "cron_expression", "rrule", "triggers", "time_as_text", "timezone"}
+
+// findSchedule and updateSchedule are the storage calls of
+// UpdateScheduleHandler; tests replace them to run without MongoDB.
+var (
+	findSchedule   = repository.FindSchedule
+	updateSchedule = repository.UpdateSchedule
+)
+
+func GetScheduleHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	schedule, err := repository.FindSchedule(ctx, mux.Vars(r)["id"])
+	if err != nil {
+		writeScheduleError(w, "Error fetching schedule: ", err)
+		return
+	}
+	writeSchedule(w, schedule)
+}
+
+// CancelScheduleHandler cancels a schedule; for a recurring series all future
+// runs are cancelled.
+func CancelScheduleHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	schedule, err := repository.CancelSchedule(ctx, mux.Vars(r)["id"])
+	if err != nil {
+		writeScheduleError(w, "Error cancelling schedule: ", err)
+		return
+	}
+	writeSchedule(w, schedule)
+}
+
+func PauseScheduleHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	schedule, err := repository.PauseSchedule(ctx, mux.Vars(r)["id"])
+	if err != nil {
+		writeScheduleError(w, "Error pausing schedule: ", err)
+		return
+	}
+	writeSchedule(w, schedule)
+}
+
+func ResumeScheduleHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	schedule, err := repository.ResumeSchedule(ctx, mux.Vars(r)["id"])
+	if err != nil {
+		writeScheduleError(w, "Error resuming schedule: ", err)
+		return
+	}
+	writeSchedule(w, schedule)
+}
+
+// UpdateScheduleHandler changes the payload, priority and/or timing of a
+// pending or paused schedule. Timing fields replace the previous timing as a
+// whole.
+func UpdateScheduleHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var tempPayload map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&tempPayload); err != nil {
+		http.Error(w, "Invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	schedule, err := findSchedule(ctx, mux.Vars(r)["id"])
+	if err != nil {
+		writeScheduleError(w, "Error fetching schedule: ", err)
+		return
+	}
+
+	if payload, ok := tempPayload["payload"]; ok {
+		payloadBytes, err := json.Marshal(payload)
+		if err != nil {
+			http.Error(w, "Failed to encode payload", http.StatusInternalServerError)
+			return
+		}
+		encryptedPayload, err := utils.Encrypt(string(payloadBytes))
+		if err != nil {
+			http.Error(w, "Failed to encrypt payload", http.StatusInternalServerError)
+			return
+		}
+		schedule.Payload = encryptedPayload
+	}
+
+	if _, ok := tempPayload["priority"]; ok {
+		priority, err := parsePriority(tempPayload)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		schedule.Priority = priority
+	}
+
+	if _, ok := tempPayload["hash_key"]; ok {
+		hashKey, err := parseHashKey(tempPayload)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		schedule.HashKey = hashKey
+	}
+
+	if hasAnyField(tempPayload, timingFields) {
+		timing := models.NewScheduler()
+		if _, err := parseTiming(ctx, tempPayload, timing); err != nil {
+			writeTimingError(w, err)
+			return
+		}
+		schedule.ScheduleTime = timing.ScheduleTime
+		schedule.CronExpression = timing.CronExpression
+		schedule.RRule = timing.RRule
+		schedule.Triggers = timing.Triggers
+		schedule.Timezone = timing.Timezone
+		schedule.StartAt = timing.StartAt
+		schedule.EndAt = timing.EndAt
+		schedule.MaxRuns = timing.MaxRuns
+		schedule.Calendar = timing.Calendar
+		schedule.CalendarRule = timing.CalendarRule
+
+		// Policies chosen for a recurring schedule may not fit a one-time one.
+		// Schedules stored before misfire policies existed have none.
+		if schedule.MisfirePolicy != "" {
+			if err := repository.ValidMisfirePolicy(schedule.MisfirePolicy, schedule.IsRecurring()); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if schedule.ConcurrencyPolicy != "" {
+			if err := repository.ValidConcurrencyPolicy(schedule.ConcurrencyPolicy, schedule.IsRecurring()); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	updated, err := updateSchedule(ctx, schedule)
+	if err != nil {
+		writeScheduleError(w, "Error updating schedule: ", err)
+		return
+	}
+	writeSchedule(w, updated)
+}
+
+// ListExecutionsHandler lists the runs of a recurring series, newest first.
+func ListExecutionsHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	seriesID := mux.Vars(r)["id"]
+	if _, err := repository.FindSchedule(ctx, seriesID); err != nil {
+		writeScheduleError(w, "Error fetching schedule: ", err)
+		return
+	}
+
+	limit := defaultExecutionsLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 || parsed > maxExecutionsLimit {
+			http.Error(w, "limit must be between 1 and "+strconv.Itoa(maxExecutionsLimit), http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+
+	executions, err := repository.ListExecutions(ctx, seriesID, int64(limit))
+	if err != nil {
+		http.Error(w, "Error listing executions: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for i := range executions {
+		executions[i].Payload = ""
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(executions)
+}
+
+func hasAnyField(tempPayload map[string]interface{}, fields []string) bool {
+	for _, field := range fields {
+		if _, ok := tempPayload[field]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// writeSchedule responds with the schedule, leaving out the encrypted payload.
+func writeSchedule(w http.ResponseWriter, schedule models.Scheduler) {
+	schedule.Payload = ""
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(schedule)
+}
+
+func writeScheduleError(w http.ResponseWriter, prefix string, err error) {
+	switch {
+	case errors.Is(err, repository.ErrScheduleNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, repository.ErrInvalidScheduleState):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, repository.ErrScheduleExhausted), errors.Is(err, repository.ErrCalendarNotFound):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, prefix+err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Sumit189/letItGo/common/models"
+	"github.com/Sumit189/letItGo/common/repository"
+	"github.com/gorilla/mux"
+)
+
+// useStoredSchedule serves stored from findSchedule and records what
+// updateSchedule is asked to save.
+func useStoredSchedule(t *testing.T, stored models.Scheduler) *models.Scheduler {
+	t.Helper()
+	saved := &models.Scheduler{}
+	previousFind, previousUpdate := findSchedule, updateSchedule
+	findSchedule = func(ctx context.Context, id string) (models.Scheduler, error) {
+		return stored, nil
+	}
+	updateSchedule = func(ctx context.Context, schedule models.Scheduler) (models.Scheduler, error) {
+		*saved = schedule
+		return schedule, nil
+	}
+	t.Cleanup(func() { findSchedule, updateSchedule = previousFind, previousUpdate })
+	return saved
+}
+
+func patchSchedule(body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPatch, "/schedule/64b7f0c2a1b2c3d4e5f60718", strings.NewReader(body))
+	req = mux.SetURLVars(req, map[string]string{"id": "64b7f0c2a1b2c3d4e5f60718"})
+	w := httptest.NewRecorder()
+	UpdateScheduleHandler(context.Background(), w, req)
+	return w
+}
+
+func TestUpdateScheduleTiming(t *testing.T) {
+	tomorrow := time.Now().Add(24 * time.Hour).UTC().Format(time.RFC3339)
+	tests := []struct {
+		name       string
+		policy     string
+		body       string
+		wantStatus int
+	}{
+		{"no stored misfire policy, recurring", "", `{"cron_expression": "0 10 * * *"}`, http.StatusOK},
+		{"no stored misfire policy, one-time", "", `{"schedule_time": "` + tomorrow + `"}`, http.StatusOK},
+		{"stored policy still valid", models.MisfireSkipToNext, `{"cron_expression": "0 10 * * *"}`, http.StatusOK},
+		{"stored policy needs a recurring schedule", models.MisfireSkipToNext, `{"schedule_time": "` + tomorrow + `"}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			saved := useStoredSchedule(t, models.Scheduler{
+				ID:             "64b7f0c2a1b2c3d4e5f60718",
+				CronExpression: "0 9 * * *",
+				Status:         "pending",
+				MisfirePolicy:  tt.policy,
+			})
+			w := patchSchedule(tt.body)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d (%s), want %d", w.Code, strings.TrimSpace(w.Body.String()), tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusOK && saved.CronExpression == "0 9 * * *" {
+				t.Error("timing was not replaced")
+			}
+		})
+	}
+}
+
+func TestWriteScheduleError(t *testing.T) {
+	tests := []struct {
+		err        error
+		wantStatus int
+	}{
+		{repository.ErrScheduleNotFound, http.StatusNotFound},
+		{repository.ErrInvalidScheduleState, http.StatusConflict},
+		{repository.ErrScheduleExhausted, http.StatusBadRequest},
+		{fmt.Errorf("calendar %q: %w", "uk-holidays", repository.ErrCalendarNotFound), http.StatusBadRequest},
+		{errors.New("connection reset"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.err.Error(), func(t *testing.T) {
+			w := httptest.NewRecorder()
+			writeScheduleError(w, "Error scheduling webhook: ", tt.err)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
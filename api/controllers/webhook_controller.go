@@ -0,0 +1,213 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	api_services "github.com/Sumit189/letItGo/api/services"
+	"github.com/Sumit189/letItGo/common/repository"
+	"github.com/gorilla/mux"
+)
+
+// VerifyWebhookHandler verifies that the caller controls a webhook endpoint
+// by sending it a one-time challenge, which the endpoint must echo back.
+// Verifying an already verified webhook renews the verification.
+func VerifyWebhookHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var payload map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	webhookURL, _ := payload["webhook_url"].(string)
+	if webhookURL == "" {
+		http.Error(w, "Missing webhook URL", http.StatusBadRequest)
+		return
+	}
+	methodType, _ := payload["method_type"].(string)
+	if methodType == "" {
+		http.Error(w, "Missing method type: [\"GET\", \"POST\"]", http.StatusBadRequest)
+		return
+	}
+	if err := api_services.ValidVerificationMethod(methodType); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := api_services.VerifyWebhook(ctx, webhookURL, methodType); err != nil {
+		log.Printf("Verification of %s %s failed: %v", methodType, webhookURL, err)
+		switch {
+		case errors.Is(err, api_services.ErrVerificationUnreachable), errors.Is(err, api_services.ErrVerificationRejected):
+			http.Error(w, "Webhook verification failed: "+err.Error(), http.StatusBadGateway)
+		case errors.Is(err, api_services.ErrChallengeExpired):
+			http.Error(w, "Webhook verification failed: "+err.Error(), http.StatusGatewayTimeout)
+		default:
+			http.Error(w, "Webhook verification failed: "+err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	if err := repository.AddVerifiedWebhook(ctx, webhookURL, methodType); err != nil {
+		http.Error(w, "Error saving verified webhook: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":     "Webhook successfully verified",
+		"webhook_url": webhookURL,
+		"method_type": methodType,
+		"verified":    true,
+		"verified_at": time.Now().UTC().Format(time.RFC3339),
+	})
+}
+
+func ListVerifiedWebhooksHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	webhooks, err := repository.ListVerifiedWebhooks(ctx)
+	if err != nil {
+		http.Error(w, "Error listing verified webhooks: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(webhooks)
+}
+
+// RevokeVerifiedWebhookHandler revokes a verification and blocks the pending
+// schedules of the webhook until it is verified again.
+func RevokeVerifiedWebhookHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	webhook, blocked, err := repository.RevokeVerifiedWebhook(ctx, mux.Vars(r)["id"])
+	if errors.Is(err, repository.ErrWebhookNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Error revoking webhook: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"webhook":           webhook,
+		"blocked_schedules": blocked,
+	})
+}
+
+// RequestDomainVerificationHandler issues the token a domain must publish,
+// as a DNS TXT record or at /.well-known/letitgo-verification, to have every
+// webhook URL on it authorized.
+func RequestDomainVerificationHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		Domain string `json:"domain"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid payload", http.StatusBadRequest)
+		return
+	}
+	domain, err := repository.NormalizeDomain(payload.Domain)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	token, err := api_services.NewDomainToken()
+	if err != nil {
+		http.Error(w, "Error generating token: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	record, err := repository.RequestDomainVerification(ctx, domain, token)
+	if err != nil {
+		http.Error(w, "Error saving domain: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	txtName, txtValue := api_services.DomainTXTRecord(record.Domain, record.Token)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"domain":   record,
+		"dns":      map[string]string{"type": "TXT", "name": txtName, "value": txtValue},
+		"http":     map[string]string{"url": api_services.DomainWellKnownURL(record.Domain), "body": record.Token},
+		"message":  "Publish either record, then call POST /domains/" + record.Domain + "/verify",
+		"verified": record.IsActive(time.Now()),
+	})
+}
+
+// VerifyDomainHandler checks that the domain publishes its token. The
+// optional "method" is dns or http; by default both are tried.
+func VerifyDomainHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		Method string `json:"method"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			http.Error(w, "Invalid payload", http.StatusBadRequest)
+			return
+		}
+	}
+	domain, err := repository.NormalizeDomain(mux.Vars(r)["domain"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	record, err := repository.GetVerifiedDomain(ctx, domain)
+	if errors.Is(err, repository.ErrDomainNotFound) {
+		http.Error(w, "Domain not found, request a token with POST /domains first", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Error fetching domain: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	method, err := api_services.VerifyDomain(ctx, domain, record.Token, payload.Method)
+	if err != nil {
+		log.Printf("Verification of domain %s failed: %v", domain, err)
+		switch {
+		case errors.Is(err, api_services.ErrDomainTokenNotFound):
+			http.Error(w, "Domain verification failed: "+err.Error(), http.StatusUnprocessableEntity)
+		case errors.Is(err, api_services.ErrVerificationUnreachable):
+			http.Error(w, "Domain verification failed: "+err.Error(), http.StatusBadGateway)
+		default:
+			http.Error(w, "Domain verification failed: "+err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	record, err = repository.MarkDomainVerified(ctx, domain, method)
+	if err != nil {
+		http.Error(w, "Error saving domain: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(record)
+}
+
+func ListVerifiedDomainsHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	domains, err := repository.ListVerifiedDomains(ctx)
+	if err != nil {
+		http.Error(w, "Error listing domains: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(domains)
+}
+
+func DeleteVerifiedDomainHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	domain, err := repository.NormalizeDomain(mux.Vars(r)["domain"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	err = repository.DeleteVerifiedDomain(ctx, domain)
+	if errors.Is(err, repository.ErrDomainNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Error deleting domain: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
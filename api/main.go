@@ -52,7 +52,18 @@ func main() {
 
 	// Initialize scheduler and connect to Redis
 	repository.InitializeSchedulerRepository()
+	repository.InitializeArchiveRepository()
+
+	// Maintenance commands run instead of the HTTP server
+	if len(os.Args) > 1 && os.Args[1] == "reencrypt" {
+		runReencrypt(ctx, os.Args[2:])
+		return
+	}
+
 	repository.InitializeVerifiedWebhooksRepository()
+	repository.InitializeVerifiedDomainsRepository()
+	repository.InitializeCalendarRepository()
+	repository.InitializeAIRepository()
 	repository.RedisConnect(ctx)
 	models.CreateIndexes(ctx)
 
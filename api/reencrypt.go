@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/Sumit189/letItGo/common/repository"
+)
+
+// runReencrypt implements "api reencrypt", which re-encrypts stored payloads
+// under the current key after a key rotation. Interrupting it is safe; the
+// next run resumes from the last written batch.
+func runReencrypt(ctx context.Context, args []string) {
+	flags := flag.NewFlagSet("reencrypt", flag.ExitOnError)
+	batchSize := flags.Int("batch-size", 500, "documents written back per batch")
+	rate := flags.Int("rate", 200, "maximum documents per second, 0 for no limit")
+	dryRun := flags.Bool("dry-run", false, "decrypt and count without writing")
+	restart := flags.Bool("restart", false, "ignore the checkpoint of an interrupted run")
+	force := flags.Bool("force", false, "also re-encrypt payloads already under the current key")
+	flags.Parse(args)
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	results, err := repository.ReencryptPayloads(ctx, repository.ReencryptionOptions{
+		BatchSize: *batchSize,
+		Rate:      *rate,
+		DryRun:    *dryRun,
+		Restart:   *restart,
+		Force:     *force,
+	}, func(p repository.ReencryptionProgress) {
+		percent := 100.0
+		if p.Total > 0 {
+			percent = float64(p.Scanned) * 100 / float64(p.Total)
+		}
+		log.Printf("%s: %d/%d scanned (%.1f%%), %d re-encrypted, %d current, %d conflicts, %d failed, %s elapsed",
+			p.Collection, p.Scanned, p.Total, percent, p.Reencrypted, p.Current, p.Conflicts, p.Failed, p.Elapsed.Round(time.Second))
+	})
+	if err != nil {
+		log.Fatalf("Re-encryption stopped: %v; run again to resume", err)
+	}
+
+	failed := int64(0)
+	for _, p := range results {
+		failed += p.Failed
+	}
+	if *dryRun {
+		log.Println("Dry run complete, nothing was written")
+	}
+	if failed > 0 {
+		log.Fatalf("Re-encryption finished with %d payloads that could not be decrypted, keep their keys in the keyring", failed)
+	}
+	log.Println("Re-encryption complete")
+}
@@ -9,7 +9,25 @@ import (
 
 func ApiRoutes(router *mux.Router) {
 	router.HandleFunc("/schedule", SchduleHandler).Methods("POST")
+	router.HandleFunc("/schedule/preview", PreviewScheduleHandler).Methods("POST")
+	router.HandleFunc("/schedule/{id}", GetScheduleHandler).Methods("GET")
+	router.HandleFunc("/schedule/{id}", UpdateScheduleHandler).Methods("PATCH")
+	router.HandleFunc("/schedule/{id}", CancelScheduleHandler).Methods("DELETE")
+	router.HandleFunc("/schedule/{id}/pause", PauseScheduleHandler).Methods("POST")
+	router.HandleFunc("/schedule/{id}/resume", ResumeScheduleHandler).Methods("POST")
+	router.HandleFunc("/schedule/{id}/executions", ListExecutionsHandler).Methods("GET")
 	router.HandleFunc("/webhook/verify", VerifyWebhookHandler).Methods("POST")
+	router.HandleFunc("/webhooks", ListVerifiedWebhooksHandler).Methods("GET")
+	router.HandleFunc("/webhooks/{id}", RevokeVerifiedWebhookHandler).Methods("DELETE")
+	router.HandleFunc("/domains", RequestDomainVerificationHandler).Methods("POST")
+	router.HandleFunc("/domains", ListVerifiedDomainsHandler).Methods("GET")
+	router.HandleFunc("/domains/{domain}/verify", VerifyDomainHandler).Methods("POST")
+	router.HandleFunc("/domains/{domain}", DeleteVerifiedDomainHandler).Methods("DELETE")
+	router.HandleFunc("/text-cache/stats", TextCacheStatsHandler).Methods("GET")
+	router.HandleFunc("/calendars", SaveCalendarHandler).Methods("POST")
+	router.HandleFunc("/calendars", ListCalendarsHandler).Methods("GET")
+	router.HandleFunc("/calendars/{name}", GetCalendarHandler).Methods("GET")
+	router.HandleFunc("/calendars/{name}", DeleteCalendarHandler).Methods("DELETE")
 	router.HandleFunc("/", APILandingPageHandler).Methods("GET")
 	router.NotFoundHandler = http.HandlerFunc(NotFoundHandler)
 }
@@ -19,11 +37,101 @@ func SchduleHandler(w http.ResponseWriter, r *http.Request) {
 	controllers.ScheduleHandler(ctx, w, r)
 }
 
+func PreviewScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	controllers.PreviewScheduleHandler(ctx, w, r)
+}
+
+func GetScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	controllers.GetScheduleHandler(ctx, w, r)
+}
+
+func UpdateScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	controllers.UpdateScheduleHandler(ctx, w, r)
+}
+
+func CancelScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	controllers.CancelScheduleHandler(ctx, w, r)
+}
+
+func PauseScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	controllers.PauseScheduleHandler(ctx, w, r)
+}
+
+func ResumeScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	controllers.ResumeScheduleHandler(ctx, w, r)
+}
+
+func ListExecutionsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	controllers.ListExecutionsHandler(ctx, w, r)
+}
+
 func VerifyWebhookHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	controllers.VerifyWebhookHandler(ctx, w, r)
 }
 
+func ListVerifiedWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	controllers.ListVerifiedWebhooksHandler(ctx, w, r)
+}
+
+func RevokeVerifiedWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	controllers.RevokeVerifiedWebhookHandler(ctx, w, r)
+}
+
+func RequestDomainVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	controllers.RequestDomainVerificationHandler(ctx, w, r)
+}
+
+func ListVerifiedDomainsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	controllers.ListVerifiedDomainsHandler(ctx, w, r)
+}
+
+func VerifyDomainHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	controllers.VerifyDomainHandler(ctx, w, r)
+}
+
+func DeleteVerifiedDomainHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	controllers.DeleteVerifiedDomainHandler(ctx, w, r)
+}
+
+func TextCacheStatsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	controllers.TextCacheStatsHandler(ctx, w, r)
+}
+
+func SaveCalendarHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	controllers.SaveCalendarHandler(ctx, w, r)
+}
+
+func ListCalendarsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	controllers.ListCalendarsHandler(ctx, w, r)
+}
+
+func GetCalendarHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	controllers.GetCalendarHandler(ctx, w, r)
+}
+
+func DeleteCalendarHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	controllers.DeleteCalendarHandler(ctx, w, r)
+}
+
 func NotFoundHandler(w http.ResponseWriter, r *http.Request) {
 	http.ServeFile(w, r, "public/404.html")
 }
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/Sumit189/letItGo/common/models"
+)
+
+const (
+	domainRecordPrefix = "_letitgo-verification."
+	domainValuePrefix  = "letitgo-verification="
+	domainWellKnown    = "/.well-known/letitgo-verification"
+)
+
+// ErrDomainTokenNotFound means the domain was reachable but did not publish
+// the expected token.
+var ErrDomainTokenNotFound = errors.New("verification token not found")
+
+// TXTResolver looks up DNS TXT records. *net.Resolver satisfies it.
+type TXTResolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// DomainResolver resolves TXT records for domain verification. When nil, the
+// resolver at DNS_RESOLVER_ADDRESS (host:port) is used if set, otherwise the
+// system resolver. Tests and local setups can point it at a stub.
+var DomainResolver TXTResolver
+
+func domainResolver() TXTResolver {
+	if DomainResolver != nil {
+		return DomainResolver
+	}
+	if address := os.Getenv("DNS_RESOLVER_ADDRESS"); address != "" {
+		return &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, network, address)
+			},
+		}
+	}
+	return net.DefaultResolver
+}
+
+// NewDomainToken returns a random token for a domain to publish.
+func NewDomainToken() (string, error) {
+	token := make([]byte, challengeBytes)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(token), nil
+}
+
+// DomainTXTRecord returns the name and value of the TXT record that proves
+// ownership of domain.
+func DomainTXTRecord(domain string, token string) (string, string) {
+	return domainRecordPrefix + domain, domainValuePrefix + token
+}
+
+// DomainWellKnownURL returns the URL the token can be served at instead.
+func DomainWellKnownURL(domain string) string {
+	return "https://" + domain + domainWellKnown
+}
+
+// VerifyDomain checks that domain publishes token, with the given method or,
+// when method is empty, with DNS and then HTTP. It returns the method that
+// succeeded.
+func VerifyDomain(ctx context.Context, domain string, token string, method string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, challengeTTL())
+	defer cancel()
+
+	switch method {
+	case models.DomainVerificationDNS:
+		return method, verifyDomainDNS(ctx, domain, token)
+	case models.DomainVerificationHTTP:
+		return method, verifyDomainHTTP(ctx, domain, token)
+	case "":
+		dnsErr := verifyDomainDNS(ctx, domain, token)
+		if dnsErr == nil {
+			return models.DomainVerificationDNS, nil
+		}
+		if httpErr := verifyDomainHTTP(ctx, domain, token); httpErr != nil {
+			return "", fmt.Errorf("dns: %w; http: %w", dnsErr, httpErr)
+		}
+		return models.DomainVerificationHTTP, nil
+	default:
+		return "", fmt.Errorf("invalid method %q, expected dns or http", method)
+	}
+}
+
+func verifyDomainDNS(ctx context.Context, domain string, token string) error {
+	name, value := DomainTXTRecord(domain, token)
+	records, err := domainResolver().LookupTXT(ctx, name)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return fmt.Errorf("%w: no TXT record at %s", ErrDomainTokenNotFound, name)
+		}
+		return fmt.Errorf("%w: %v", ErrVerificationUnreachable, err)
+	}
+	for _, record := range records {
+		if hmac.Equal([]byte(strings.TrimSpace(record)), []byte(value)) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: no TXT record at %s matches", ErrDomainTokenNotFound, name)
+}
+
+func verifyDomainHTTP(ctx context.Context, domain string, token string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, DomainWellKnownURL(domain), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := verificationClient.Do(req)
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("%w: %v", ErrVerificationUnreachable, ctx.Err())
+		}
+		return fmt.Errorf("%w: %v", ErrVerificationUnreachable, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%w: %s returned status %d", ErrDomainTokenNotFound, DomainWellKnownURL(domain), resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxChallengeResponseBytes))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrVerificationUnreachable, err)
+	}
+	if !hmac.Equal([]byte(strings.TrimSpace(string(body))), []byte(token)) {
+		return fmt.Errorf("%w: %s does not contain the token", ErrDomainTokenNotFound, DomainWellKnownURL(domain))
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Sumit189/letItGo/common/models"
+)
+
+const testDomainToken = "3f7a9c"
+
+// stubResolver answers TXT lookups from a map; missing names are NXDOMAIN.
+type stubResolver struct {
+	records map[string][]string
+	err     error
+}
+
+func (s stubResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	records, ok := s.records[name]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	return records, nil
+}
+
+func useResolver(t *testing.T, resolver TXTResolver) {
+	t.Helper()
+	previous := DomainResolver
+	DomainResolver = resolver
+	t.Cleanup(func() { DomainResolver = previous })
+}
+
+// serveWellKnown starts a TLS server for the well-known path and returns its
+// host:port, to be used as the domain.
+func serveWellKnown(t *testing.T, handler http.HandlerFunc) string {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc(domainWellKnown, handler)
+	server := httptest.NewTLSServer(mux)
+	t.Cleanup(server.Close)
+
+	previous := verificationClient.Transport
+	verificationClient.Transport = server.Client().Transport
+	t.Cleanup(func() { verificationClient.Transport = previous })
+	return strings.TrimPrefix(server.URL, "https://")
+}
+
+func TestVerifyDomainDNS(t *testing.T) {
+	tests := []struct {
+		name     string
+		resolver stubResolver
+		wantErr  error
+	}{
+		{"matching record", stubResolver{records: map[string][]string{
+			"_letitgo-verification.example.com": {"other", " letitgo-verification=" + testDomainToken + " "},
+		}}, nil},
+		{"wrong token", stubResolver{records: map[string][]string{
+			"_letitgo-verification.example.com": {"letitgo-verification=wrong"},
+		}}, ErrDomainTokenNotFound},
+		{"record on the domain itself", stubResolver{records: map[string][]string{
+			"example.com": {"letitgo-verification=" + testDomainToken},
+		}}, ErrDomainTokenNotFound},
+		{"no record", stubResolver{}, ErrDomainTokenNotFound},
+		{"resolver failure", stubResolver{err: &net.DNSError{Err: "server misbehaving", IsTemporary: true}}, ErrVerificationUnreachable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useResolver(t, tt.resolver)
+			err := verifyDomainDNS(context.Background(), "example.com", testDomainToken)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("verifyDomainDNS() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyDomainHTTP(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		wantErr error
+	}{
+		{"token served", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(testDomainToken + "\n"))
+		}, nil},
+		{"wrong token", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("wrong"))
+		}, ErrDomainTokenNotFound},
+		{"not found", http.NotFound, ErrDomainTokenNotFound},
+		{"redirect is not followed", func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, "https://elsewhere.example.com/", http.StatusFound)
+		}, ErrDomainTokenNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			domain := serveWellKnown(t, tt.handler)
+			err := verifyDomainHTTP(context.Background(), domain, testDomainToken)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("verifyDomainHTTP() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyDomainFallsBackToHTTP(t *testing.T) {
+	useResolver(t, stubResolver{})
+	domain := serveWellKnown(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testDomainToken))
+	})
+
+	method, err := VerifyDomain(context.Background(), domain, testDomainToken, "")
+	if err != nil {
+		t.Fatalf("VerifyDomain() error = %v", err)
+	}
+	if method != models.DomainVerificationHTTP {
+		t.Fatalf("VerifyDomain() method = %q, want %q", method, models.DomainVerificationHTTP)
+	}
+
+	if _, err := VerifyDomain(context.Background(), domain, testDomainToken, models.DomainVerificationDNS); !errors.Is(err, ErrDomainTokenNotFound) {
+		t.Fatalf("VerifyDomain(dns) error = %v, want %v", err, ErrDomainTokenNotFound)
+	}
+}
@@ -0,0 +1,194 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	challengeType             = "url_verification"
+	challengeBytes            = 32
+	defaultChallengeTTL       = 10 * time.Second
+	maxChallengeResponseBytes = 64 << 10
+)
+
+// Verification failures, told apart by VerifyWebhookHandler to pick the
+// response status.
+var (
+	ErrVerificationUnreachable = errors.New("webhook endpoint could not be reached")
+	ErrVerificationRejected    = errors.New("webhook endpoint rejected the verification request")
+	ErrChallengeMismatch       = errors.New("webhook endpoint did not echo the challenge")
+	ErrChallengeExpired        = errors.New("verification challenge expired before the endpoint answered")
+)
+
+// verificationMethods are the methods a webhook can be verified, and
+// therefore scheduled, with.
+var verificationMethods = map[string]bool{
+	http.MethodGet:    true,
+	http.MethodPost:   true,
+	http.MethodPut:    true,
+	http.MethodPatch:  true,
+	http.MethodDelete: true,
+}
+
+// verificationClient does not follow redirects: the endpoint that answers
+// the challenge must be the one being verified.
+var verificationClient = &http.Client{
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// VerificationChallenge is sent to the endpoint being verified. For GET the
+// fields are sent as query parameters instead of a JSON body.
+type VerificationChallenge struct {
+	Type       string    `json:"type"`
+	Challenge  string    `json:"challenge"`
+	WebhookURL string    `json:"webhook_url"`
+	MethodType string    `json:"method_type"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+func ValidVerificationMethod(method string) error {
+	if !verificationMethods[method] {
+		return fmt.Errorf("invalid method_type %q, expected one of GET, POST, PUT, PATCH, DELETE", method)
+	}
+	return nil
+}
+
+// VerifyWebhook sends a random, single-use challenge to the endpoint with its
+// declared method, in the style of Slack's url_verification. The endpoint
+// proves it is controlled by the caller by echoing the challenge, as JSON
+// {"challenge": "..."} or as plain text, before the challenge expires
+// (WEBHOOK_CHALLENGE_TTL_SECONDS). The request is signed with
+// WEBHOOK_SECRET_KEY so the endpoint can tell it comes from LetItGo.
+func VerifyWebhook(ctx context.Context, webhookURL string, methodType string) error {
+	if err := ValidVerificationMethod(methodType); err != nil {
+		return err
+	}
+	target, err := url.Parse(webhookURL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return errors.New("webhook_url must be an absolute http or https URL")
+	}
+
+	token := make([]byte, challengeBytes)
+	if _, err := rand.Read(token); err != nil {
+		return err
+	}
+	challenge := VerificationChallenge{
+		Type:       challengeType,
+		Challenge:  hex.EncodeToString(token),
+		WebhookURL: webhookURL,
+		MethodType: methodType,
+		ExpiresAt:  time.Now().Add(challengeTTL()).UTC(),
+	}
+	ctx, cancel := context.WithDeadline(ctx, challenge.ExpiresAt)
+	defer cancel()
+
+	req, err := newChallengeRequest(ctx, target, challenge)
+	if err != nil {
+		return err
+	}
+	resp, err := verificationClient.Do(req)
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return ErrChallengeExpired
+		}
+		return fmt.Errorf("%w: %v", ErrVerificationUnreachable, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%w: status %d", ErrVerificationRejected, resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxChallengeResponseBytes))
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return ErrChallengeExpired
+		}
+		return fmt.Errorf("%w: %v", ErrVerificationUnreachable, err)
+	}
+	if time.Now().After(challenge.ExpiresAt) {
+		return ErrChallengeExpired
+	}
+	if !challengeEchoed(body, challenge.Challenge) {
+		return ErrChallengeMismatch
+	}
+	return nil
+}
+
+func newChallengeRequest(ctx context.Context, target *url.URL, challenge VerificationChallenge) (*http.Request, error) {
+	var body io.Reader
+	if challenge.MethodType == http.MethodGet {
+		query := target.Query()
+		query.Set("type", challenge.Type)
+		query.Set("challenge", challenge.Challenge)
+		query.Set("expires_at", challenge.ExpiresAt.Format(time.RFC3339))
+		withQuery := *target
+		withQuery.RawQuery = query.Encode()
+		target = &withQuery
+	} else {
+		data, err := json.Marshal(challenge)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, challenge.MethodType, target.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("X-LetItGo-Timestamp", timestamp)
+	if secretKey := os.Getenv("WEBHOOK_SECRET_KEY"); secretKey != "" {
+		req.Header.Set("X-LetItGo-Signature", GenerateSignature(timestamp+"."+challenge.Challenge, secretKey))
+	}
+	return req, nil
+}
+
+// challengeEchoed reports whether the response body carries the challenge,
+// either as {"challenge": "..."} or as the whole plain-text body.
+func challengeEchoed(body []byte, challenge string) bool {
+	var response struct {
+		Challenge string `json:"challenge"`
+	}
+	echoed := strings.TrimSpace(string(body))
+	if json.Unmarshal(body, &response) == nil && response.Challenge != "" {
+		echoed = response.Challenge
+	}
+	return hmac.Equal([]byte(echoed), []byte(challenge))
+}
+
+func challengeTTL() time.Duration {
+	if value := os.Getenv("WEBHOOK_CHALLENGE_TTL_SECONDS"); value != "" {
+		if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+	return defaultChallengeTTL
+}
+
+// GenerateSignature returns the hex HMAC-SHA256 of message under secretKey.
+func GenerateSignature(message, secretKey string) string {
+	mac := hmac.New(sha256.New, []byte(secretKey))
+	mac.Write([]byte(message))
+	return hex.EncodeToString(mac.Sum(nil))
+}
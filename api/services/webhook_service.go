@@ -25,11 +25,17 @@ var (
 
 func Schedule(ctx context.Context, scheduler models.Scheduler) (models.Scheduler, error) {
 	// Validation checks
-	if scheduler.ScheduleTime == nil && scheduler.CronExpression == "" {
-		return models.Scheduler{}, errors.New("either schedule_time or cron_expression must be provided")
+	if scheduler.ScheduleTime == nil && !scheduler.IsRecurring() {
+		return models.Scheduler{}, errors.New("either schedule_time, cron_expression, rrule or triggers must be provided")
 	}
-	if scheduler.ScheduleTime != nil && scheduler.CronExpression != "" {
-		return models.Scheduler{}, errors.New("schedule_time and cron_expression cannot both be set")
+	if scheduler.ScheduleTime != nil && scheduler.IsRecurring() {
+		return models.Scheduler{}, errors.New("schedule_time cannot be combined with cron_expression, rrule or triggers")
+	}
+	if scheduler.CronExpression != "" && scheduler.RRule != "" {
+		return models.Scheduler{}, errors.New("cron_expression and rrule cannot both be set")
+	}
+	if len(scheduler.Triggers) > 0 && (scheduler.CronExpression != "" || scheduler.RRule != "") {
+		return models.Scheduler{}, errors.New("triggers cannot be combined with cron_expression or rrule")
 	}
 
 	// Encrypt the payload
@@ -0,0 +1,70 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+const (
+	defaultAnthropicURL   = "https://api.anthropic.com/v1/messages"
+	defaultAnthropicModel = "claude-3-5-haiku-latest"
+	anthropicVersion      = "2023-06-01"
+)
+
+// anthropicProvider talks to Anthropic-style messages APIs.
+type anthropicProvider struct {
+	config Config
+	client *http.Client
+}
+
+func newAnthropicProvider(config Config, client *http.Client) (*anthropicProvider, error) {
+	if config.APIKey == "" {
+		return nil, errors.New("environment variable LLM_API_KEY is not set")
+	}
+	if config.URL == "" {
+		config.URL = defaultAnthropicURL
+	}
+	if config.Model == "" {
+		config.Model = defaultAnthropicModel
+	}
+	return &anthropicProvider{config: config, client: client}, nil
+}
+
+func (p *anthropicProvider) Name() string { return "anthropic" }
+
+func (p *anthropicProvider) Complete(ctx context.Context, request Request) (string, error) {
+	// The messages API has no JSON mode; the system prompt asks for JSON
+	body := map[string]interface{}{
+		"model":       p.config.Model,
+		"system":      request.System,
+		"messages":    request.Messages,
+		"max_tokens":  maxTokens(p.config, request),
+		"temperature": p.config.Temperature,
+	}
+	headers := map[string]string{
+		"x-api-key":         strings.TrimPrefix(p.config.APIKey, "Bearer "),
+		"anthropic-version": anthropicVersion,
+	}
+
+	var result struct {
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+	}
+	if err := postJSON(ctx, p.client, p.config.URL, headers, body, &result); err != nil {
+		return "", err
+	}
+	var text strings.Builder
+	for _, block := range result.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+	if text.Len() == 0 {
+		return "", errEmptyCompletion
+	}
+	return text.String(), nil
+}
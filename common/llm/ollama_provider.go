@@ -0,0 +1,59 @@
+package llm
+
+import (
+	"context"
+	"net/http"
+)
+
+const (
+	defaultOllamaURL   = "http://localhost:11434/api/chat"
+	defaultOllamaModel = "llama3"
+)
+
+// ollamaProvider talks to the chat API of Ollama and other local servers
+// exposing the same interface. No API key is needed.
+type ollamaProvider struct {
+	config Config
+	client *http.Client
+}
+
+func newOllamaProvider(config Config, client *http.Client) *ollamaProvider {
+	if config.URL == "" {
+		config.URL = defaultOllamaURL
+	}
+	if config.Model == "" {
+		config.Model = defaultOllamaModel
+	}
+	return &ollamaProvider{config: config, client: client}
+}
+
+func (p *ollamaProvider) Name() string { return "ollama" }
+
+func (p *ollamaProvider) Complete(ctx context.Context, request Request) (string, error) {
+	messages := []Message{{Role: "system", Content: request.System}}
+	messages = append(messages, request.Messages...)
+
+	body := map[string]interface{}{
+		"model":    p.config.Model,
+		"messages": messages,
+		"stream":   false,
+		"options": map[string]interface{}{
+			"temperature": p.config.Temperature,
+			"num_predict": maxTokens(p.config, request),
+		},
+	}
+	if request.JSON {
+		body["format"] = "json"
+	}
+
+	var result struct {
+		Message Message `json:"message"`
+	}
+	if err := postJSON(ctx, p.client, p.config.URL, nil, body, &result); err != nil {
+		return "", err
+	}
+	if result.Message.Content == "" {
+		return "", errEmptyCompletion
+	}
+	return result.Message.Content, nil
+}
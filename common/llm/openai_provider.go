@@ -0,0 +1,65 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+const defaultOpenAIModel = "llama3-70b-8192"
+
+// openAIProvider talks to OpenAI-compatible chat completion APIs, such as
+// OpenAI, Groq or vLLM.
+type openAIProvider struct {
+	config Config
+	client *http.Client
+}
+
+func newOpenAIProvider(config Config, client *http.Client) (*openAIProvider, error) {
+	if config.URL == "" || config.APIKey == "" {
+		return nil, errors.New("environment variables LLM_API_URL or LLM_API_KEY are not set")
+	}
+	if config.Model == "" {
+		config.Model = defaultOpenAIModel
+	}
+	return &openAIProvider{config: config, client: client}, nil
+}
+
+func (p *openAIProvider) Name() string { return "openai" }
+
+func (p *openAIProvider) Complete(ctx context.Context, request Request) (string, error) {
+	messages := []Message{{Role: "system", Content: request.System}}
+	messages = append(messages, request.Messages...)
+
+	body := map[string]interface{}{
+		"messages":    messages,
+		"model":       p.config.Model,
+		"temperature": p.config.Temperature,
+		"max_tokens":  maxTokens(p.config, request),
+		"top_p":       1,
+		"stream":      false,
+	}
+	if request.JSON {
+		body["response_format"] = map[string]string{"type": "json_object"}
+	}
+
+	// Keys used to be configured with their scheme, keep accepting both forms
+	authorization := p.config.APIKey
+	if !strings.HasPrefix(authorization, "Bearer ") {
+		authorization = "Bearer " + authorization
+	}
+
+	var result struct {
+		Choices []struct {
+			Message Message `json:"message"`
+		} `json:"choices"`
+	}
+	if err := postJSON(ctx, p.client, p.config.URL, map[string]string{"Authorization": authorization}, body, &result); err != nil {
+		return "", err
+	}
+	if len(result.Choices) == 0 || result.Choices[0].Message.Content == "" {
+		return "", errEmptyCompletion
+	}
+	return result.Choices[0].Message.Content, nil
+}
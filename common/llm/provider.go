@@ -0,0 +1,162 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTimeout   = 15 * time.Second
+	defaultMaxTokens = 512
+	maxErrorBody     = 512
+)
+
+var errEmptyCompletion = errors.New("LLM API returned no content")
+
+// Message is a single chat turn.
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// Request is a provider-neutral completion request.
+type Request struct {
+	System    string
+	Messages  []Message
+	JSON      bool // ask for a JSON object when the provider supports it
+	MaxTokens int  // overrides Config.MaxTokens when set
+}
+
+// Provider completes chat requests against a language model API.
+type Provider interface {
+	Name() string
+	Complete(ctx context.Context, request Request) (string, error)
+}
+
+// Config selects and configures a provider.
+type Config struct {
+	Provider    string // openai, ollama, anthropic or stub
+	URL         string
+	APIKey      string
+	Model       string
+	Temperature float64
+	Timeout     time.Duration
+	MaxTokens   int // completion limit for requests that do not set their own
+}
+
+// ConfigFromEnv reads LLM_PROVIDER, LLM_API_URL, LLM_API_KEY, LLM_MODEL,
+// LLM_TEMPERATURE, LLM_TIMEOUT_SECONDS and LLM_MAX_TOKENS.
+func ConfigFromEnv() (Config, error) {
+	config := Config{
+		Provider:  strings.ToLower(os.Getenv("LLM_PROVIDER")),
+		URL:       os.Getenv("LLM_API_URL"),
+		APIKey:    os.Getenv("LLM_API_KEY"),
+		Model:     os.Getenv("LLM_MODEL"),
+		Timeout:   defaultTimeout,
+		MaxTokens: defaultMaxTokens,
+	}
+	if config.Provider == "" {
+		config.Provider = "openai"
+	}
+	if value := os.Getenv("LLM_TEMPERATURE"); value != "" {
+		temperature, err := strconv.ParseFloat(value, 64)
+		if err != nil || temperature < 0 || temperature > 2 {
+			return Config{}, fmt.Errorf("invalid LLM_TEMPERATURE: %s", value)
+		}
+		config.Temperature = temperature
+	}
+	if value := os.Getenv("LLM_TIMEOUT_SECONDS"); value != "" {
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds <= 0 {
+			return Config{}, fmt.Errorf("invalid LLM_TIMEOUT_SECONDS: %s", value)
+		}
+		config.Timeout = time.Duration(seconds) * time.Second
+	}
+	if value := os.Getenv("LLM_MAX_TOKENS"); value != "" {
+		tokens, err := strconv.Atoi(value)
+		if err != nil || tokens <= 0 {
+			return Config{}, fmt.Errorf("invalid LLM_MAX_TOKENS: %s", value)
+		}
+		config.MaxTokens = tokens
+	}
+	return config, nil
+}
+
+// NewProvider builds the provider named in the config.
+func NewProvider(config Config) (Provider, error) {
+	if config.Timeout <= 0 {
+		config.Timeout = defaultTimeout
+	}
+	client := &http.Client{Timeout: config.Timeout}
+
+	switch config.Provider {
+	case "openai":
+		return newOpenAIProvider(config, client)
+	case "ollama":
+		return newOllamaProvider(config, client), nil
+	case "anthropic":
+		return newAnthropicProvider(config, client)
+	case "stub":
+		return &StubProvider{Responses: []string{os.Getenv("LLM_STUB_RESPONSE")}}, nil
+	}
+	return nil, fmt.Errorf("unknown LLM_PROVIDER: %s", config.Provider)
+}
+
+// NewProviderFromEnv builds the provider configured by the environment.
+func NewProviderFromEnv() (Provider, error) {
+	config, err := ConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return NewProvider(config)
+}
+
+// maxTokens returns the completion limit of a request: its own, else the
+// configured one, else defaultMaxTokens.
+func maxTokens(config Config, request Request) int {
+	if request.MaxTokens > 0 {
+		return request.MaxTokens
+	}
+	if config.MaxTokens > 0 {
+		return config.MaxTokens
+	}
+	return defaultMaxTokens
+}
+
+// postJSON sends body as JSON and decodes a successful response into out.
+func postJSON(ctx context.Context, client *http.Client, url string, headers map[string]string, body interface{}, out interface{}) error {
+	requestBody, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(requestBody))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		errorBody, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return fmt.Errorf("LLM API returned %s: %s", resp.Status, strings.TrimSpace(string(errorBody)))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
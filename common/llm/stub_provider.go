@@ -0,0 +1,48 @@
+package llm
+
+import (
+	"context"
+	"sync"
+)
+
+// StubProvider returns canned responses without any network access, for
+// exercising the natural-language path in tests and local setups. Responses
+// are returned in order; the last one is repeated once they run out. With
+// LLM_PROVIDER=stub the single response is read from LLM_STUB_RESPONSE.
+type StubProvider struct {
+	Responses []string
+	Err       error
+
+	mu       sync.Mutex
+	requests []Request
+}
+
+func (p *StubProvider) Name() string { return "stub" }
+
+func (p *StubProvider) Complete(ctx context.Context, request Request) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	p.requests = append(p.requests, request)
+	if p.Err != nil {
+		return "", p.Err
+	}
+	if len(p.Responses) == 0 {
+		return "", errEmptyCompletion
+	}
+	index := len(p.requests) - 1
+	if index >= len(p.Responses) {
+		index = len(p.Responses) - 1
+	}
+	return p.Responses[index], nil
+}
+
+// Requests returns the requests received so far.
+func (p *StubProvider) Requests() []Request {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Request(nil), p.requests...)
+}
@@ -9,7 +9,26 @@ type Archive struct {
 	Payload                    string     `json:"payload" bson:"payload"`                                               // Encrypted payload to send
 	ScheduleTime               *time.Time `json:"schedule_time" bson:"schedule_time"`                                   // Specific time for one-time triggers (pointer to handle nil)
 	CronExpression             string     `json:"cron_expression,omitempty" bson:"cron_expression,omitempty"`           // Cron for recurring schedules (optional)
+	RRule                      string     `json:"rrule,omitempty" bson:"rrule,omitempty"`                               // iCalendar RRULE for recurring schedules (optional)
+	Triggers                   []Trigger  `json:"triggers,omitempty" bson:"triggers,omitempty"`                         // Rules of a schedule that runs whenever any of them is due (optional)
 	NextRunTime                *time.Time `json:"next_run_time,omitempty" bson:"next_run_time,omitempty"`               // Next run time for cron schedules
+	Timezone                   string     `json:"timezone,omitempty" bson:"timezone,omitempty"`                         // IANA zone the cron is evaluated in (defaults to UTC)
+	StartAt                    *time.Time `json:"start_at,omitempty" bson:"start_at,omitempty"`                         // Earliest time a recurring schedule may run
+	EndAt                      *time.Time `json:"end_at,omitempty" bson:"end_at,omitempty"`                             // No recurring runs are scheduled after this time
+	MaxRuns                    int        `json:"max_runs,omitempty" bson:"max_runs,omitempty"`                         // Stop the recurring schedule after this many runs (0 = unlimited)
+	MisfirePolicy              string     `json:"misfire_policy,omitempty" bson:"misfire_policy,omitempty"`             // fire_now, skip_to_next, fire_all_missed, fail
+	MisfireToleranceSeconds    int        `json:"misfire_tolerance_seconds" bson:"misfire_tolerance_seconds"`           // Lateness tolerated before the misfire policy applies
+	ConcurrencyPolicy          string     `json:"concurrency_policy,omitempty" bson:"concurrency_policy,omitempty"`     // allow, forbid, replace
+	SeriesID                   string     `json:"series_id,omitempty" bson:"series_id,omitempty"`                       // ID of the recurring series this run belongs to
+	JitterSeconds              int        `json:"jitter_seconds,omitempty" bson:"jitter_seconds,omitempty"`             // Random delay of up to this many seconds added to each run
+	HashKey                    string     `json:"hash_key,omitempty" bson:"hash_key,omitempty"`                         // Extra input to the hash behind H tokens in cron fields
+	Calendar                   string     `json:"calendar,omitempty" bson:"calendar,omitempty"`                         // Name of the business calendar excluding dates
+	CalendarRule               string     `json:"calendar_rule,omitempty" bson:"calendar_rule,omitempty"`               // skip, next_business_day, previous_business_day
+	Priority                   int        `json:"priority,omitempty" bson:"priority"`                                   // low (-1), normal (0), high (1), critical (2)
+	MaxLatenessSeconds         int        `json:"max_lateness,omitempty" bson:"max_lateness,omitempty"`                 // Runs later than this past their scheduled time expire
+	StatusReason               string     `json:"status_reason,omitempty" bson:"status_reason,omitempty"`               // Why the schedule reached its status, e.g. expired
+	BlockedFrom                string     `json:"blocked_from,omitempty" bson:"blocked_from,omitempty"`                 // Status before the schedule was blocked, restored when unblocked
+	CancelRequested            bool       `json:"cancel_requested,omitempty" bson:"cancel_requested,omitempty"`         // The series was cancelled while this run was executing
 	Status                     string     `json:"status" bson:"status"`                                                 // pending, in-progress, completed, failed
 	Retries                    int        `json:"retries" bson:"retries"`                                               // Number of retries
 	RetryLimit                 int        `json:"retry_limit" bson:"retry_limit"`                                       // Retry limit
@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// Calendar rules decide how a schedule treats runs that fall on a date
+// excluded by its calendar.
+const (
+	CalendarSkip                = "skip"                  // drop the run
+	CalendarNextBusinessDay     = "next_business_day"     // move the run to the next business day
+	CalendarPreviousBusinessDay = "previous_business_day" // move the run to the previous business day
+)
+
+// Calendar is a named list of non-business dates such as public holidays or
+// company shutdown days.
+type Calendar struct {
+	ID        string    `json:"id,omitempty" bson:"_id,omitempty"`
+	Name      string    `json:"name" bson:"name"`             // Unique name schedules reference the calendar by
+	Dates     []string  `json:"dates" bson:"dates"`           // Excluded dates as YYYY-MM-DD
+	CreatedAt time.Time `json:"created_at" bson:"created_at"` // Calendar creation timestamp
+	UpdatedAt time.Time `json:"updated_at" bson:"updated_at"` // Last updated timestamp
+}
+
+func NewCalendar() *Calendar {
+	return &Calendar{
+		Dates:     []string{},
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+}
+
+// Excludes reports whether the calendar date of t is listed.
+func (c Calendar) Excludes(t time.Time) bool {
+	date := t.Format(time.DateOnly)
+	for _, excluded := range c.Dates {
+		if excluded == date {
+			return true
+		}
+	}
+	return false
+}
+
+// IsBusinessDay reports whether t falls on a weekday that is not excluded.
+func (c Calendar) IsBusinessDay(t time.Time) bool {
+	if t.Weekday() == time.Saturday || t.Weekday() == time.Sunday {
+		return false
+	}
+	return !c.Excludes(t)
+}
@@ -2,6 +2,48 @@ package models
 
 import "time"
 
+// Misfire policies decide what happens to a schedule picked up later than its
+// misfire tolerance allows.
+const (
+	MisfireFireNow       = "fire_now"        // run once as soon as possible
+	MisfireSkipToNext    = "skip_to_next"    // drop the missed run and wait for the next occurrence
+	MisfireFireAllMissed = "fire_all_missed" // run every missed occurrence back to back
+	MisfireFail          = "fail"            // archive the schedule as failed
+)
+
+// Concurrency policies decide what happens when a recurring run becomes due
+// while the previous run of the same series is still executing.
+const (
+	ConcurrencyAllow   = "allow"   // let runs overlap
+	ConcurrencyForbid  = "forbid"  // skip the new run
+	ConcurrencyReplace = "replace" // cancel the in-flight run and start the new one
+)
+
+// Priority levels order schedules that are due at the same time; higher
+// values are dispatched first.
+const (
+	PriorityLow      = -1
+	PriorityNormal   = 0
+	PriorityHigh     = 1
+	PriorityCritical = 2
+)
+
+// PriorityLevels maps priority names accepted by the API to their levels.
+var PriorityLevels = map[string]int{
+	"low":      PriorityLow,
+	"normal":   PriorityNormal,
+	"high":     PriorityHigh,
+	"critical": PriorityCritical,
+}
+
+// Trigger is one rule of a schedule with several triggers, such as "Mondays
+// at 9" and "the 1st at noon". Exactly one field is set.
+type Trigger struct {
+	ScheduleTime   *time.Time `json:"schedule_time,omitempty" bson:"schedule_time,omitempty"`
+	CronExpression string     `json:"cron_expression,omitempty" bson:"cron_expression,omitempty"`
+	RRule          string     `json:"rrule,omitempty" bson:"rrule,omitempty"`
+}
+
 // Scheduler represents a task to trigger a webhook at a scheduled time or based on a cron expression.
 type Scheduler struct {
 	ID                         string     `json:"id,omitempty" bson:"_id,omitempty"`
@@ -9,7 +51,26 @@ type Scheduler struct {
 	Payload                    string     `json:"payload" bson:"payload"`                                               // Encrypted payload to send
 	ScheduleTime               *time.Time `json:"schedule_time" bson:"schedule_time"`                                   // Specific time for one-time triggers (pointer to handle nil)
 	CronExpression             string     `json:"cron_expression,omitempty" bson:"cron_expression,omitempty"`           // Cron for recurring schedules (optional)
+	RRule                      string     `json:"rrule,omitempty" bson:"rrule,omitempty"`                               // iCalendar RRULE for recurring schedules (optional)
+	Triggers                   []Trigger  `json:"triggers,omitempty" bson:"triggers,omitempty"`                         // Rules of a schedule that runs whenever any of them is due (optional)
 	NextRunTime                *time.Time `json:"next_run_time,omitempty" bson:"next_run_time,omitempty"`               // Next run time for cron schedules
+	Timezone                   string     `json:"timezone,omitempty" bson:"timezone,omitempty"`                         // IANA zone the cron is evaluated in (defaults to UTC)
+	StartAt                    *time.Time `json:"start_at,omitempty" bson:"start_at,omitempty"`                         // Earliest time a recurring schedule may run
+	EndAt                      *time.Time `json:"end_at,omitempty" bson:"end_at,omitempty"`                             // No recurring runs are scheduled after this time
+	MaxRuns                    int        `json:"max_runs,omitempty" bson:"max_runs,omitempty"`                         // Stop the recurring schedule after this many runs (0 = unlimited)
+	MisfirePolicy              string     `json:"misfire_policy,omitempty" bson:"misfire_policy,omitempty"`             // fire_now, skip_to_next, fire_all_missed, fail
+	MisfireToleranceSeconds    int        `json:"misfire_tolerance_seconds" bson:"misfire_tolerance_seconds"`           // Lateness tolerated before the misfire policy applies
+	ConcurrencyPolicy          string     `json:"concurrency_policy,omitempty" bson:"concurrency_policy,omitempty"`     // allow, forbid, replace
+	SeriesID                   string     `json:"series_id,omitempty" bson:"series_id,omitempty"`                       // ID of the recurring series this run belongs to
+	JitterSeconds              int        `json:"jitter_seconds,omitempty" bson:"jitter_seconds,omitempty"`             // Random delay of up to this many seconds added to each run
+	HashKey                    string     `json:"hash_key,omitempty" bson:"hash_key,omitempty"`                         // Extra input to the hash behind H tokens in cron fields
+	Calendar                   string     `json:"calendar,omitempty" bson:"calendar,omitempty"`                         // Name of the business calendar excluding dates
+	CalendarRule               string     `json:"calendar_rule,omitempty" bson:"calendar_rule,omitempty"`               // skip, next_business_day, previous_business_day
+	Priority                   int        `json:"priority,omitempty" bson:"priority"`                                   // low (-1), normal (0), high (1), critical (2)
+	MaxLatenessSeconds         int        `json:"max_lateness,omitempty" bson:"max_lateness,omitempty"`                 // Runs later than this past their scheduled time expire
+	StatusReason               string     `json:"status_reason,omitempty" bson:"status_reason,omitempty"`               // Why the schedule reached its status, e.g. expired
+	BlockedFrom                string     `json:"blocked_from,omitempty" bson:"blocked_from,omitempty"`                 // Status before the schedule was blocked, restored when unblocked
+	CancelRequested            bool       `json:"cancel_requested,omitempty" bson:"cancel_requested,omitempty"`         // The series was cancelled while this run was executing
 	Status                     string     `json:"status" bson:"status"`                                                 // pending, in-progress, completed, failed
 	Retries                    int        `json:"retries" bson:"retries"`                                               // Number of retries
 	RetryLimit                 int        `json:"retry_limit" bson:"retry_limit"`                                       // Retry limit
@@ -30,8 +91,49 @@ func NewScheduler() *Scheduler {
 		WebhookRetryLimit:   3,
 		WebhookRetryCount:   0,
 		Status:              "pending",
+		MisfirePolicy:       MisfireFireNow,
 		RunCount:            0,
 		CreatedAt:           time.Now(),
 		UpdatedAt:           time.Now(),
 	}
 }
+
+// IsRecurring reports whether the schedule can run more than once, on a cron
+// expression, an RRULE or a set of triggers.
+func (s Scheduler) IsRecurring() bool {
+	return s.CronExpression != "" || s.RRule != "" || len(s.Triggers) > 0
+}
+
+// PriorityName returns the API name of the schedule's priority.
+func (s Scheduler) PriorityName() string {
+	for name, level := range PriorityLevels {
+		if level == s.Priority {
+			return name
+		}
+	}
+	return "normal"
+}
+
+// Deadline returns the latest time the run may still start, measured from
+// its scheduled time, and false when the schedule has no max_lateness.
+func (s Scheduler) Deadline() (time.Time, bool) {
+	if s.MaxLatenessSeconds <= 0 {
+		return time.Time{}, false
+	}
+	scheduledFor := s.ScheduleTime
+	if scheduledFor == nil {
+		scheduledFor = s.NextRunTime
+	}
+	if scheduledFor == nil {
+		return time.Time{}, false
+	}
+	return scheduledFor.Add(time.Duration(s.MaxLatenessSeconds) * time.Second), true
+}
+
+// SeriesKey identifies the recurring series a run belongs to.
+func (s Scheduler) SeriesKey() string {
+	if s.SeriesID != "" {
+		return s.SeriesID
+	}
+	return s.ID
+}
@@ -2,18 +2,114 @@ package models
 
 import (
 	"context"
+	"errors"
+	"log"
 
 	"github.com/Sumit189/letItGo/common/database"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoDB error codes returned when an index with the same name or keys but
+// different options already exists.
+const (
+	indexOptionsConflict  = 85
+	indexKeySpecsConflict = 86
 )
 
 func CreateIndexes(ctx context.Context) {
+	// A webhook has at most one verification record
 	VerifiedWebhooks := database.GetCollection("verifiedwebhooks")
-	VerifiedWebhooks.Indexes().CreateOne(ctx, mongo.IndexModel{
-		Keys: bson.M{
-			"webhook_url": 1,
-			"method_type": 1,
-		},
+	if err := dedupeVerifiedWebhooks(ctx, VerifiedWebhooks); err != nil {
+		log.Printf("Failed to remove duplicate verified webhooks: %v", err)
+	}
+	uniqueWebhook := mongo.IndexModel{
+		Keys:    bson.D{{Key: "webhook_url", Value: 1}, {Key: "method_type", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}
+	if _, err := VerifiedWebhooks.Indexes().CreateOne(ctx, uniqueWebhook); err != nil {
+		var serverErr mongo.ServerError
+		if errors.As(err, &serverErr) && (serverErr.HasErrorCode(indexOptionsConflict) || serverErr.HasErrorCode(indexKeySpecsConflict)) {
+			// Earlier versions created a non-unique index on the same keys
+			if _, dropErr := VerifiedWebhooks.Indexes().DropOne(ctx, "webhook_url_1_method_type_1"); dropErr != nil {
+				log.Printf("Failed to replace index on verified webhooks: %v", dropErr)
+			} else if _, err = VerifiedWebhooks.Indexes().CreateOne(ctx, uniqueWebhook); err != nil {
+				log.Printf("Failed to create unique index on verified webhooks, restoring the previous index: %v", err)
+				VerifiedWebhooks.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: uniqueWebhook.Keys})
+			}
+		} else {
+			log.Printf("Failed to create unique index on verified webhooks: %v", err)
+		}
+	}
+
+	// A domain has at most one verification record
+	database.GetCollection("verifieddomains").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.M{"domain": 1},
+		Options: options.Index().SetUnique(true),
 	})
+
+	// Schedules stored before priority was always written lack the field,
+	// which sorts below low; backfill normal priority
+	for _, collection := range []string{"schedulers", "archives"} {
+		if _, err := database.GetCollection(collection).UpdateMany(ctx,
+			bson.M{"priority": bson.M{"$exists": false}},
+			bson.M{"$set": bson.M{"priority": PriorityNormal}},
+		); err != nil {
+			log.Printf("Failed to backfill priority of %s: %v", collection, err)
+		}
+	}
+
+	// Due schedules are fetched by status, highest priority first
+	database.GetCollection("schedulers").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "status", Value: 1}, {Key: "priority", Value: -1}, {Key: "next_run_time", Value: 1}},
+	})
+
+	// Executions of a recurring series are looked up by series_id
+	for _, collection := range []string{"schedulers", "archives"} {
+		database.GetCollection(collection).Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys: bson.M{"series_id": 1},
+		})
+	}
+
+	Calendars := database.GetCollection("calendars")
+	Calendars.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.M{"name": 1},
+		Options: options.Index().SetUnique(true),
+	})
+}
+
+// dedupeVerifiedWebhooks keeps one verification record per webhook URL and
+// method, so the unique index can be built. A revoked record is kept over an
+// active one, so a revocation is never undone; otherwise the most recently
+// updated verified record wins.
+func dedupeVerifiedWebhooks(ctx context.Context, collection *mongo.Collection) error {
+	cursor, err := collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$sort", Value: bson.D{{Key: "revoked_at", Value: -1}, {Key: "verified", Value: -1}, {Key: "updated_at", Value: -1}, {Key: "_id", Value: -1}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   bson.M{"webhook_url": "$webhook_url", "method_type": "$method_type"},
+			"ids":   bson.M{"$push": "$_id"},
+			"count": bson.M{"$sum": 1},
+		}}},
+		{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+	})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var group struct {
+			IDs []interface{} `bson:"ids"`
+		}
+		if err := cursor.Decode(&group); err != nil {
+			return err
+		}
+		result, err := collection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": group.IDs[1:]}})
+		if err != nil {
+			return err
+		}
+		log.Printf("Removed %d duplicate verified webhook records", result.DeletedCount)
+	}
+	return cursor.Err()
 }
@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// Domain verification methods.
+const (
+	DomainVerificationDNS  = "dns"  // TXT record at _letitgo-verification.<domain>
+	DomainVerificationHTTP = "http" // token served at https://<domain>/.well-known/letitgo-verification
+)
+
+// VerifiedDomain records the ownership verification of a domain. A verified
+// domain authorizes every webhook URL on it.
+type VerifiedDomain struct {
+	ID         string     `json:"id,omitempty" bson:"_id,omitempty"`
+	Domain     string     `json:"domain" bson:"domain"`                               // Lowercase host name, e.g. api.example.com
+	Token      string     `json:"token" bson:"token"`                                 // Value the domain owner publishes
+	Method     string     `json:"method,omitempty" bson:"method,omitempty"`           // dns or http, once verified
+	Verified   bool       `json:"verified" bson:"verified"`                           // Verified status
+	VerifiedAt *time.Time `json:"verified_at,omitempty" bson:"verified_at,omitempty"` // Last successful verification
+	ExpiresAt  *time.Time `json:"expires_at,omitempty" bson:"expires_at,omitempty"`   // Re-verification is required after this time
+	CreatedAt  time.Time  `json:"created_at" bson:"created_at"`                       // Record creation timestamp
+	UpdatedAt  time.Time  `json:"updated_at" bson:"updated_at"`                       // Last updated timestamp
+}
+
+// IsActive reports whether the domain is verified and not expired at the
+// given time.
+func (d VerifiedDomain) IsActive(now time.Time) bool {
+	return d.Verified && (d.ExpiresAt == nil || d.ExpiresAt.After(now))
+}
+
+// Authorizes reports whether the verification covers the host. A DNS record
+// proves control of the whole zone, so subdomains are covered too; a file
+// served over HTTP only covers the host that served it.
+func (d VerifiedDomain) Authorizes(host string) bool {
+	if host == d.Domain {
+		return true
+	}
+	return d.Method == DomainVerificationDNS && len(host) > len(d.Domain) && host[len(host)-len(d.Domain)-1:] == "."+d.Domain
+}
@@ -3,12 +3,15 @@ package models
 import "time"
 
 type VerifiedWebhooks struct {
-	ID         string    `json:"id,omitempty" bson:"_id,omitempty"`
-	WebhookURL string    `json:"webhook_url" bson:"webhook_url"` // The URL to trigger
-	MethodType string    `json:"method_type" bson:"method_type"` // HTTP method type
-	Verified   bool      `json:"verified" bson:"verified"`       // Verified status
-	CreatedAt  time.Time `json:"created_at" bson:"created_at"`   // Task creation timestamp
-	UpdatedAt  time.Time `json:"updated_at" bson:"updated_at"`   // Last updated timestamp
+	ID         string     `json:"id,omitempty" bson:"_id,omitempty"`
+	WebhookURL string     `json:"webhook_url" bson:"webhook_url"`                     // The URL to trigger
+	MethodType string     `json:"method_type" bson:"method_type"`                     // HTTP method type
+	Verified   bool       `json:"verified" bson:"verified"`                           // Verified status
+	VerifiedAt *time.Time `json:"verified_at,omitempty" bson:"verified_at,omitempty"` // Last successful verification
+	ExpiresAt  *time.Time `json:"expires_at,omitempty" bson:"expires_at,omitempty"`   // Re-verification is required after this time
+	RevokedAt  *time.Time `json:"revoked_at,omitempty" bson:"revoked_at,omitempty"`   // Set while the verification is revoked
+	CreatedAt  time.Time  `json:"created_at" bson:"created_at"`                       // Task creation timestamp
+	UpdatedAt  time.Time  `json:"updated_at" bson:"updated_at"`                       // Last updated timestamp
 }
 
 func NewVerifiedWebhooks() *VerifiedWebhooks {
@@ -17,3 +20,9 @@ func NewVerifiedWebhooks() *VerifiedWebhooks {
 		UpdatedAt: time.Now(),
 	}
 }
+
+// IsActive reports whether the webhook is verified, not revoked and not
+// expired at the given time.
+func (v VerifiedWebhooks) IsActive(now time.Time) bool {
+	return v.Verified && v.RevokedAt == nil && (v.ExpiresAt == nil || v.ExpiresAt.After(now))
+}
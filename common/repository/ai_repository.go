@@ -1,14 +1,17 @@
 package repository
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
-	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/Sumit189/letItGo/common/llm"
 )
 
 const (
@@ -18,13 +21,20 @@ const (
 
 		- If the input is a specific time (e.g., "next Monday at 3 PM"), convert it into an ISO 8601 time string in UTC.
 		- If the input is a cron expression (e.g., "every day at 3:00 PM"), return the corresponding cron expression.
-		- If the input doesn't match either format, return null for both fields.
+		- If the input doesn't match either format, or could mean several different times, return null for both fields and explain why in **ambiguity**.
 		- Only one of the following should be returned: **timeString** (in ISO format in UTC) or **cronExpression**. Both cannot be returned at the same time.
+		- If the input combines several rules that one cron expression cannot express (e.g. "Mondays at 9, plus the 1st at noon"), return null for both fields and list the rules in **triggers**, each an object with one timeString or cronExpression.
+		- A timeString must be in the future.
+		- The input can be in any language; the locale, when given, names it.
+		- Read times of day in the user's timezone (e.g. "9am" is 9am local time) and convert the timeString to UTC.
+		- Cron expressions are evaluated in the user's timezone, so write them in local time.
 		- Only return json and nothing else.
 		- Return a JSON object with the following structure:
 			{
 				"timeString": "<ISO 8601 time string in UTC or null>",
-				"cronExpression": "<cron expression or null>"
+				"cronExpression": "<cron expression or null>",
+				"ambiguity": "<why the input cannot be resolved, or null>",
+				"triggers": [{"timeString": "<...>", "cronExpression": "<...>"}] or null
 			}
 
 		### **Examples:**
@@ -32,90 +42,233 @@ const (
 		-	**Output:**
 			{
 				"timeString": "2025-01-22T15:00:00Z",
-				"cronExpression": null
+				"cronExpression": null,
+				"ambiguity": null
 			}
 		- 	**Input:** "Every day at 3:00 PM"
 		-	**Output:**
 			{
 				"timeString": null,
-				"cronExpression": "0 15 * * *"
+				"cronExpression": "0 15 * * *",
+				"ambiguity": null
+			}
+		- 	**Input:** "Mondays and Thursdays at 9, plus the 1st at noon"
+		-	**Output:**
+			{
+				"timeString": null,
+				"cronExpression": null,
+				"ambiguity": null,
+				"triggers": [{"cronExpression": "0 9 * * 1,4"}, {"cronExpression": "0 12 1 * *"}]
 			}
 		`
+
+	defaultLLMRepairAttempts = 2
+	defaultTextHorizonDays   = 366
 )
 
-func TextToTimeOrCronExpression(ctx context.Context, text string) (string, bool, error) {
-	url := os.Getenv("LLM_API_URL")
-	apiKey := os.Getenv("LLM_API_KEY")
+// llmAnswer is the JSON object the model is asked to return.
+type llmAnswer struct {
+	TimeString     string      `json:"timeString"`
+	CronExpression string      `json:"cronExpression"`
+	Ambiguity      string      `json:"ambiguity"`
+	Triggers       []llmAnswer `json:"triggers"`
+}
+
+func (a llmAnswer) empty() bool {
+	return a.TimeString == "" && a.CronExpression == "" && len(a.Triggers) == 0
+}
 
-	if url == "" || apiKey == "" {
-		log.Println("environment variables LLM_API_URL or LLM_API_KEY are not set")
-		return "", false, fmt.Errorf("environment variables LLM_API_URL or LLM_API_KEY are not set")
+// schedule checks the shape of the answer and converts it. The values are
+// validated separately.
+func (a llmAnswer) schedule() (TextSchedule, error) {
+	set := 0
+	for _, present := range []bool{a.TimeString != "", a.CronExpression != "", len(a.Triggers) > 0} {
+		if present {
+			set++
+		}
+	}
+	if set > 1 {
+		return TextSchedule{}, errors.New("only one of timeString, cronExpression or triggers may be returned")
+	}
+	if a.TimeString != "" {
+		return TextSchedule{Value: a.TimeString, Source: TextSourceLLM}, nil
+	}
+	if a.CronExpression != "" {
+		return TextSchedule{Value: a.CronExpression, IsCron: true, Source: TextSourceLLM}, nil
 	}
 
-	currentTimeInUTC := time.Now().UTC().Format(time.RFC3339)
-	userText := fmt.Sprintf("Ask: %s, Current time in UTC: %s", text, currentTimeInUTC)
-	requestBody, err := json.Marshal(map[string]interface{}{
-		"messages": []map[string]string{
-			{
-				"role":    "system",
-				"content": systemPrompt,
-			},
-			{
-				"role":    "user",
-				"content": userText,
-			},
-		},
-		"model":       "llama3-70b-8192",
-		"temperature": 0,
-		"max_tokens":  100,
-		"top_p":       1,
-		"stream":      false,
-		"stop":        nil,
-		"response_format": map[string]string{
-			"type": "json_object",
-		},
-	})
+	compound := TextSchedule{Source: TextSourceLLM}
+	for _, trigger := range a.Triggers {
+		if len(trigger.Triggers) > 0 || (trigger.TimeString == "") == (trigger.CronExpression == "") {
+			return TextSchedule{}, errors.New("each trigger must have exactly one timeString or cronExpression")
+		}
+		schedule, _ := trigger.schedule()
+		compound.Triggers = append(compound.Triggers, schedule)
+	}
+	if len(compound.Triggers) == 1 {
+		return compound.Triggers[0], nil
+	}
+	return compound, nil
+}
+
+// TextScheduleError explains why a natural-language schedule could not be
+// turned into a usable time or cron expression.
+type TextScheduleError struct {
+	Text     string `json:"text"`
+	Reason   string `json:"reason"`
+	Result   string `json:"result,omitempty"`
+	Source   string `json:"source"`
+	Attempts int    `json:"attempts"`
+}
 
+func (e *TextScheduleError) Error() string {
+	return fmt.Sprintf("could not interpret %q: %s", e.Text, e.Reason)
+}
+
+// LLMProvider converts natural-language schedules. It is set from the
+// environment by InitializeAIRepository and can be replaced, e.g. with an
+// llm.StubProvider in tests.
+var LLMProvider llm.Provider
+
+func InitializeAIRepository() {
+	provider, err := llm.NewProviderFromEnv()
 	if err != nil {
-		return "", false, err
+		log.Printf("Natural-language scheduling disabled: %v", err)
+		return
 	}
+	LLMProvider = provider
+	log.Printf("Natural-language scheduling uses the %s provider", provider.Name())
+}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(requestBody))
-	if err != nil {
-		return "", false, err
+// TextToTimeOrCronExpression asks the LLM to resolve text, giving it the
+// current time in now's location and the locale, if any. Answers that are
+// malformed, in the past, beyond the horizon or invalid cron expressions are
+// sent back to the model with the validation error, up to LLM_REPAIR_ATTEMPTS
+// times. A *TextScheduleError is returned when no usable answer is given.
+func TextToTimeOrCronExpression(ctx context.Context, text string, locale string, now time.Time) (TextSchedule, error) {
+	if LLMProvider == nil {
+		return TextSchedule{}, errors.New("no LLM provider is configured")
 	}
-	req.Header.Add("Content-Type", "application/json")
-	req.Header.Add("Authorization", apiKey)
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", false, err
+	userText := fmt.Sprintf("Ask: %s, Current time in UTC: %s, User timezone: %s, Current local time: %s",
+		text, now.UTC().Format(time.RFC3339), now.Location(), now.Format(time.RFC3339))
+	if locale != "" {
+		userText += ", Locale: " + locale
 	}
-	defer resp.Body.Close()
+	messages := []llm.Message{{Role: "user", Content: userText}}
+	textErr := &TextScheduleError{Text: text, Source: TextSourceLLM}
+	for textErr.Attempts <= llmRepairAttempts() {
+		textErr.Attempts++
+		content, err := LLMProvider.Complete(ctx, llm.Request{
+			System:   systemPrompt,
+			Messages: messages,
+			JSON:     true,
+		})
+		if err != nil {
+			return TextSchedule{}, err
+		}
 
-	var result map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", false, err
+		var answer llmAnswer
+		if err := json.Unmarshal([]byte(extractJSONObject(content)), &answer); err != nil {
+			textErr.Reason = "response is not a JSON object"
+			textErr.Result = content
+		} else if answer.empty() {
+			// The model gave up; asking again will not make the text less ambiguous
+			textErr.Reason = answer.Ambiguity
+			textErr.Result = ""
+			if textErr.Reason == "" {
+				textErr.Reason = "text does not describe a time or schedule"
+			}
+			return TextSchedule{}, textErr
+		} else {
+			schedule, err := answer.schedule()
+			if err == nil {
+				textErr.Result = schedule.summary()
+				err = validateTextResult(schedule, now)
+			} else {
+				textErr.Result = extractJSONObject(content)
+			}
+			if err == nil {
+				return schedule, nil
+			}
+			textErr.Reason = err.Error()
+		}
+
+		log.Printf("Rejected LLM answer %q for %q: %s", textErr.Result, text, textErr.Reason)
+		messages = append(messages,
+			llm.Message{Role: "assistant", Content: content},
+			llm.Message{Role: "user", Content: fmt.Sprintf(
+				"That answer is invalid: %s. Reply with corrected JSON only, or with null for all fields and an ambiguity if the input cannot be resolved.",
+				textErr.Reason,
+			)},
+		)
 	}
+	return TextSchedule{}, textErr
+}
 
-	if choices, ok := result["choices"].([]interface{}); ok && len(choices) > 0 {
-		if message, ok := choices[0].(map[string]interface{})["message"].(map[string]interface{}); ok {
-			if content, ok := message["content"].(string); ok {
-				var response map[string]interface{}
-				if err := json.Unmarshal([]byte(content), &response); err != nil {
-					return "", false, err
-				}
+// ValidateTextSchedule checks a resolved natural-language schedule: a time
+// must be RFC3339 and in the future, a cron expression must pass ValidateCron,
+// and either must first fire within NL_MAX_HORIZON_DAYS.
+func ValidateTextSchedule(value string, isCron bool, now time.Time) error {
+	horizonDays := textHorizonDays()
+	horizon := now.AddDate(0, 0, horizonDays)
 
-				if timeString, ok := response["timeString"].(string); ok {
-					return timeString, false, nil
-				} else if cronExpression, ok := response["cronExpression"].(string); ok {
-					return cronExpression, true, nil
-				}
-				return "", false, nil
-			}
+	if isCron {
+		if err := ValidateCron(value); err != nil {
+			return err
+		}
+		expanded, err := ExpandHashedCron(value, "")
+		if err != nil {
+			return err
+		}
+		schedule, err := ParseCron(expanded)
+		if err != nil {
+			return err
+		}
+		if next := schedule.Next(now); next.IsZero() || next.After(horizon) {
+			return fmt.Errorf("cron expression %q does not fire within %d days", value, horizonDays)
+		}
+		return nil
+	}
+
+	runTime, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return fmt.Errorf("%q is not an RFC3339 time", value)
+	}
+	if !runTime.After(now) {
+		return fmt.Errorf("%s is in the past", value)
+	}
+	if runTime.After(horizon) {
+		return fmt.Errorf("%s is more than %d days ahead", value, horizonDays)
+	}
+	return nil
+}
+
+func llmRepairAttempts() int {
+	if value := os.Getenv("LLM_REPAIR_ATTEMPTS"); value != "" {
+		if attempts, err := strconv.Atoi(value); err == nil && attempts >= 0 {
+			return attempts
 		}
 	}
+	return defaultLLMRepairAttempts
+}
+
+func textHorizonDays() int {
+	if value := os.Getenv("NL_MAX_HORIZON_DAYS"); value != "" {
+		if days, err := strconv.Atoi(value); err == nil && days > 0 {
+			return days
+		}
+	}
+	return defaultTextHorizonDays
+}
 
-	return "", false, nil
+// extractJSONObject strips prose or code fences some models put around the
+// JSON object they were asked for.
+func extractJSONObject(content string) string {
+	start := strings.Index(content, "{")
+	end := strings.LastIndex(content, "}")
+	if start < 0 || end < start {
+		return content
+	}
+	return content[start : end+1]
 }
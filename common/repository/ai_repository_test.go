@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Sumit189/letItGo/common/llm"
+)
+
+// useStubLLM answers natural-language requests with the given responses for
+// the duration of a test.
+func useStubLLM(t *testing.T, responses ...string) *llm.StubProvider {
+	t.Helper()
+	stub := &llm.StubProvider{Responses: responses}
+	previous := LLMProvider
+	LLMProvider = stub
+	t.Cleanup(func() { LLMProvider = previous })
+	return stub
+}
+
+func TestTextToTimeOrCronExpressionRepairs(t *testing.T) {
+	t.Setenv("LLM_REPAIR_ATTEMPTS", "2")
+	now := time.Date(2025, 6, 11, 10, 0, 0, 0, time.UTC)
+	stub := useStubLLM(t,
+		"Sure! Every morning at nine.",
+		`{"timeString": "2020-01-01T09:00:00Z"}`,
+		"```json\n{\"cronExpression\": \"0 9 * * *\"}\n```",
+	)
+
+	schedule, err := TextToTimeOrCronExpression(context.Background(), "every morning at nine", "", now)
+	if err != nil {
+		t.Fatalf("TextToTimeOrCronExpression: %v", err)
+	}
+	if !schedule.IsCron || schedule.Value != "0 9 * * *" || schedule.Source != TextSourceLLM {
+		t.Fatalf("schedule = %+v, want cron 0 9 * * * from the llm", schedule)
+	}
+
+	requests := stub.Requests()
+	if len(requests) != 3 {
+		t.Fatalf("made %d requests, want 3", len(requests))
+	}
+	// Each repair request carries the conversation so far and the reason
+	last := requests[2].Messages
+	if len(last) != 5 {
+		t.Fatalf("last request has %d messages, want 5", len(last))
+	}
+	if last[3].Role != "assistant" || !strings.Contains(last[3].Content, "2020-01-01") {
+		t.Errorf("rejected answer not sent back: %+v", last[3])
+	}
+	if !strings.Contains(last[4].Content, "That answer is invalid") {
+		t.Errorf("repair prompt = %q", last[4].Content)
+	}
+}
+
+func TestTextToTimeOrCronExpressionGivesUp(t *testing.T) {
+	t.Setenv("LLM_REPAIR_ATTEMPTS", "1")
+	now := time.Date(2025, 6, 11, 10, 0, 0, 0, time.UTC)
+	stub := useStubLLM(t, `{"cronExpression": "every day"}`)
+
+	_, err := TextToTimeOrCronExpression(context.Background(), "daily-ish", "", now)
+	var textErr *TextScheduleError
+	if !errors.As(err, &textErr) {
+		t.Fatalf("error = %v, want a TextScheduleError", err)
+	}
+	if textErr.Attempts != 2 || textErr.Source != TextSourceLLM || textErr.Result != "every day" {
+		t.Errorf("error = %+v, want 2 llm attempts ending in %q", textErr, "every day")
+	}
+	if len(stub.Requests()) != 2 {
+		t.Errorf("made %d requests, want 2", len(stub.Requests()))
+	}
+}
+
+func TestTextToTimeOrCronExpressionStops(t *testing.T) {
+	now := time.Date(2025, 6, 11, 10, 0, 0, 0, time.UTC)
+
+	t.Run("ambiguous text is not repaired", func(t *testing.T) {
+		stub := useStubLLM(t, `{"timeString": null, "cronExpression": null, "ambiguity": "which friday?"}`)
+		_, err := TextToTimeOrCronExpression(context.Background(), "friday", "", now)
+		var textErr *TextScheduleError
+		if !errors.As(err, &textErr) || textErr.Reason != "which friday?" {
+			t.Fatalf("error = %v, want the model's ambiguity", err)
+		}
+		if len(stub.Requests()) != 1 {
+			t.Errorf("made %d requests, want 1", len(stub.Requests()))
+		}
+	})
+
+	t.Run("provider errors are returned", func(t *testing.T) {
+		stub := useStubLLM(t)
+		stub.Err = errors.New("rate limited")
+		_, err := TextToTimeOrCronExpression(context.Background(), "tomorrow", "", now)
+		if !errors.Is(err, stub.Err) {
+			t.Fatalf("error = %v, want %v", err, stub.Err)
+		}
+	})
+}
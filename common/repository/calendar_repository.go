@@ -0,0 +1,377 @@
+package repository
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Sumit189/letItGo/common/database"
+	"github.com/Sumit189/letItGo/common/models"
+
+	"github.com/teambition/rrule-go"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// maxCalendarShift bounds how far a business-day rule may move a run.
+	maxCalendarShift = 31 * 24 * time.Hour
+	// maxCalendarScan bounds how many occurrences are inspected for one run.
+	maxCalendarScan = 1000
+	// maxICSEventDays bounds how many days a single ICS event may cover.
+	maxICSEventDays = 366
+	// maxICSOccurrences bounds how many occurrences of a recurring ICS event
+	// are expanded.
+	maxICSOccurrences = 1000
+	// icsRecurrenceYears is how far ahead recurring ICS events without COUNT
+	// or UNTIL are expanded.
+	icsRecurrenceYears = 10
+)
+
+var CalendarCollection *mongo.Collection
+
+var ErrCalendarNotFound = errors.New("calendar not found")
+
+func InitializeCalendarRepository() {
+	CalendarCollection = database.GetCollection("calendars")
+}
+
+// ValidCalendarRule reports whether the rule is known.
+func ValidCalendarRule(rule string) error {
+	switch rule {
+	case models.CalendarSkip, models.CalendarNextBusinessDay, models.CalendarPreviousBusinessDay:
+		return nil
+	}
+	return fmt.Errorf("invalid calendar_rule: %s", rule)
+}
+
+// SaveCalendar creates the calendar or replaces the dates of an existing one
+// with the same name.
+func SaveCalendar(ctx context.Context, calendar models.Calendar) (models.Calendar, error) {
+	calendar.UpdatedAt = time.Now()
+	update := bson.M{
+		"$set": bson.M{
+			"dates":      calendar.Dates,
+			"updated_at": calendar.UpdatedAt,
+		},
+		"$setOnInsert": bson.M{
+			"name":       calendar.Name,
+			"created_at": calendar.CreatedAt,
+		},
+	}
+
+	var saved models.Calendar
+	err := CalendarCollection.FindOneAndUpdate(
+		ctx,
+		bson.M{"name": calendar.Name},
+		update,
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&saved)
+	if err != nil {
+		return models.Calendar{}, err
+	}
+	return saved, nil
+}
+
+func FindCalendar(ctx context.Context, name string) (models.Calendar, error) {
+	var calendar models.Calendar
+	err := CalendarCollection.FindOne(ctx, bson.M{"name": name}).Decode(&calendar)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return models.Calendar{}, ErrCalendarNotFound
+		}
+		return models.Calendar{}, err
+	}
+	return calendar, nil
+}
+
+func ListCalendars(ctx context.Context) ([]models.Calendar, error) {
+	cursor, err := CalendarCollection.Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"name": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	calendars := []models.Calendar{}
+	if err := cursor.All(ctx, &calendars); err != nil {
+		return nil, err
+	}
+	return calendars, nil
+}
+
+// DeleteCalendar removes the calendar unless a schedule still references it.
+func DeleteCalendar(ctx context.Context, name string) error {
+	inUse, err := SchedulerCollection.CountDocuments(ctx, bson.M{"calendar": name})
+	if err != nil {
+		return err
+	}
+	if inUse > 0 {
+		return fmt.Errorf("calendar %s is used by %d schedules", name, inUse)
+	}
+
+	result, err := CalendarCollection.DeleteOne(ctx, bson.M{"name": name})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrCalendarNotFound
+	}
+	return nil
+}
+
+// NormalizeCalendarDates validates YYYY-MM-DD dates, removing duplicates and
+// sorting them.
+func NormalizeCalendarDates(dates []string) ([]string, error) {
+	seen := map[string]bool{}
+	normalized := []string{}
+	for _, date := range dates {
+		parsed, err := time.Parse(time.DateOnly, strings.TrimSpace(date))
+		if err != nil {
+			return nil, fmt.Errorf("invalid calendar date: %s", date)
+		}
+		formatted := parsed.Format(time.DateOnly)
+		if !seen[formatted] {
+			seen[formatted] = true
+			normalized = append(normalized, formatted)
+		}
+	}
+	sort.Strings(normalized)
+	return normalized, nil
+}
+
+// ParseICSDates extracts the dates covered by the VEVENTs of an iCalendar
+// file. All-day events cover DTSTART up to, but excluding, DTEND. Recurring
+// events are expanded from their RRULE, without the dates in EXDATE; rules
+// without COUNT or UNTIL are expanded icsRecurrenceYears ahead.
+func ParseICSDates(data string) ([]string, error) {
+	return parseICSDates(data, time.Now())
+}
+
+func parseICSDates(data string, now time.Time) ([]string, error) {
+	var (
+		dates     []string
+		inEvent   bool
+		startDate string
+		endDate   string
+		rule      string
+		exDates   map[string]bool
+		unfolded  []string
+		scanner   = bufio.NewScanner(strings.NewReader(data))
+		horizon   = now.AddDate(icsRecurrenceYears, 0, 0)
+	)
+
+	// Continuation lines start with a space or tab (RFC 5545 section 3.1)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(unfolded) > 0 {
+			unfolded[len(unfolded)-1] += line[1:]
+			continue
+		}
+		unfolded = append(unfolded, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, line := range unfolded {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		name, _, _ = strings.Cut(strings.ToUpper(name), ";")
+
+		switch {
+		case name == "BEGIN" && strings.EqualFold(value, "VEVENT"):
+			inEvent, startDate, endDate, rule, exDates = true, "", "", "", map[string]bool{}
+		case name == "DTSTART" && inEvent:
+			startDate = value
+		case name == "DTEND" && inEvent:
+			endDate = value
+		case name == "RRULE" && inEvent:
+			rule = value
+		case name == "EXDATE" && inEvent:
+			for _, exDate := range strings.Split(value, ",") {
+				excluded, err := parseICSDateOnly(exDate)
+				if err != nil {
+					return nil, err
+				}
+				exDates[excluded.Format(time.DateOnly)] = true
+			}
+		case name == "END" && strings.EqualFold(value, "VEVENT"):
+			inEvent = false
+			if startDate == "" {
+				continue
+			}
+			start, err := parseICSDateOnly(startDate)
+			if err != nil {
+				return nil, err
+			}
+			days := 1
+			if endDate != "" {
+				end, err := parseICSDateOnly(endDate)
+				if err != nil {
+					return nil, err
+				}
+				if end.After(start) {
+					days = min(int(end.Sub(start).Hours()/24), maxICSEventDays)
+				}
+			}
+
+			starts := []time.Time{start}
+			if rule != "" {
+				if starts, err = expandICSRule(rule, start, horizon); err != nil {
+					return nil, err
+				}
+			}
+			for _, occurrence := range starts {
+				if exDates[occurrence.Format(time.DateOnly)] {
+					continue
+				}
+				for day := 0; day < days; day++ {
+					dates = append(dates, occurrence.AddDate(0, 0, day).Format(time.DateOnly))
+				}
+			}
+		}
+	}
+
+	if len(dates) == 0 {
+		return nil, errors.New("no events found in ICS data")
+	}
+	return NormalizeCalendarDates(dates)
+}
+
+func parseICSDateOnly(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if len(value) < 8 {
+		return time.Time{}, fmt.Errorf("invalid ICS date: %s", value)
+	}
+	return time.Parse("20060102", value[:8])
+}
+
+// expandICSRule returns the start dates of a recurring all-day event up to
+// the horizon, at most maxICSOccurrences of them.
+func expandICSRule(value string, start time.Time, horizon time.Time) ([]time.Time, error) {
+	options, err := rrule.StrToROption(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ICS RRULE %q: %v", value, err)
+	}
+	options.Dtstart = start
+	rule, err := rrule.NewRRule(*options)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ICS RRULE %q: %v", value, err)
+	}
+
+	var starts []time.Time
+	next := rule.Iterator()
+	for len(starts) < maxICSOccurrences {
+		occurrence, ok := next()
+		if !ok || occurrence.After(horizon) {
+			break
+		}
+		starts = append(starts, occurrence)
+	}
+	return starts, nil
+}
+
+// applyCalendarRule adjusts a run that falls on an excluded date. It reports
+// false when the run must be dropped. Runs are compared by their date in loc;
+// business-day rules move the run to the same wall-clock time on the nearest
+// weekday that is not excluded.
+func applyCalendarRule(calendar models.Calendar, rule string, runTime time.Time, loc *time.Location) (time.Time, bool) {
+	local := runTime.In(loc)
+	switch rule {
+	case models.CalendarNextBusinessDay, models.CalendarPreviousBusinessDay:
+		if calendar.IsBusinessDay(local) {
+			return runTime, true
+		}
+		step := 1
+		if rule == models.CalendarPreviousBusinessDay {
+			step = -1
+		}
+		for shifted := local.AddDate(0, 0, step); absDuration(shifted.Sub(local)) <= maxCalendarShift; shifted = shifted.AddDate(0, 0, step) {
+			if calendar.IsBusinessDay(shifted) {
+				return shifted.UTC(), true
+			}
+		}
+		return time.Time{}, false
+	default:
+		if calendar.Excludes(local) {
+			return time.Time{}, false
+		}
+		return runTime, true
+	}
+}
+
+// AdjustToCalendar applies a schedule's calendar to a one-time run.
+func AdjustToCalendar(ctx context.Context, calendarName string, rule string, runTime time.Time, timezone string) (time.Time, error) {
+	calendar, err := FindCalendar(ctx, calendarName)
+	if err != nil {
+		return time.Time{}, err
+	}
+	loc, err := LoadTimezone(timezone)
+	if err != nil {
+		return time.Time{}, errors.New("invalid timezone: " + timezone)
+	}
+	adjusted, ok := applyCalendarRule(calendar, rule, runTime, loc)
+	if !ok {
+		return time.Time{}, fmt.Errorf("schedule_time falls on a date excluded by calendar %s", calendarName)
+	}
+	return adjusted, nil
+}
+
+// nextCalendarRunTime returns the earliest adjusted occurrence after the given
+// instant. Business-day rules can move later occurrences before earlier ones,
+// so occurrences are scanned until none can beat the best candidate.
+func nextCalendarRunTime(calendar models.Calendar, rule string, timezone string, after time.Time, nextOccurrence func(time.Time) (time.Time, error)) (time.Time, error) {
+	loc, err := LoadTimezone(timezone)
+	if err != nil {
+		return time.Time{}, errors.New("invalid timezone: " + timezone)
+	}
+
+	var best time.Time
+	cursor := after
+	for i := 0; i < maxCalendarScan; i++ {
+		occurrence, err := nextOccurrence(cursor)
+		if err != nil {
+			if best.IsZero() {
+				return time.Time{}, err
+			}
+			break
+		}
+		if !best.IsZero() && occurrence.Sub(best) > maxCalendarShift {
+			break
+		}
+
+		adjusted, ok := applyCalendarRule(calendar, rule, occurrence, loc)
+		if ok && adjusted.After(after) && (best.IsZero() || adjusted.Before(best)) {
+			best = adjusted
+		}
+		if rule != models.CalendarPreviousBusinessDay && !best.IsZero() && !occurrence.Before(best) {
+			break
+		}
+
+		cursor = occurrence
+		// Skip the rest of an excluded day for schedules that run more than
+		// once a day
+		if local := occurrence.In(loc); calendar.Excludes(local) {
+			cursor = time.Date(local.Year(), local.Month(), local.Day()+1, 0, 0, 0, 0, loc).Add(-time.Second)
+		}
+	}
+
+	if best.IsZero() {
+		return time.Time{}, errors.New("no run found outside the calendar's excluded dates")
+	}
+	return best, nil
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
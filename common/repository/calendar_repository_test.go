@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Sumit189/letItGo/common/models"
+)
+
+// ics wraps event lines in a VCALENDAR with CRLF line endings.
+func ics(lines ...string) string {
+	return strings.Join(append(append([]string{"BEGIN:VCALENDAR"}, lines...), "END:VCALENDAR"), "\r\n")
+}
+
+func TestParseICSDates(t *testing.T) {
+	now := time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		data    string
+		want    []string
+		wantErr bool
+	}{
+		{"single day", ics("BEGIN:VEVENT", "DTSTART;VALUE=DATE:20260101", "END:VEVENT"), []string{"2026-01-01"}, false},
+		{"date-time start", ics("BEGIN:VEVENT", "DTSTART:20260101T090000Z", "END:VEVENT"), []string{"2026-01-01"}, false},
+		{"end is exclusive", ics("BEGIN:VEVENT", "DTSTART;VALUE=DATE:20261224", "DTEND;VALUE=DATE:20261227", "END:VEVENT"),
+			[]string{"2026-12-24", "2026-12-25", "2026-12-26"}, false},
+		{"folded line", ics("BEGIN:VEVENT", "DTSTART;VALUE=DATE:2026", " 0704", "END:VEVENT"), []string{"2026-07-04"}, false},
+		{"several events, sorted and deduplicated", ics(
+			"BEGIN:VEVENT", "DTSTART;VALUE=DATE:20261225", "END:VEVENT",
+			"BEGIN:VEVENT", "DTSTART;VALUE=DATE:20260101", "END:VEVENT",
+			"BEGIN:VEVENT", "DTSTART;VALUE=DATE:20261225", "END:VEVENT",
+		), []string{"2026-01-01", "2026-12-25"}, false},
+		{"yearly with COUNT", ics("BEGIN:VEVENT", "DTSTART;VALUE=DATE:20260101", "RRULE:FREQ=YEARLY;COUNT=3", "END:VEVENT"),
+			[]string{"2026-01-01", "2027-01-01", "2028-01-01"}, false},
+		{"yearly with date UNTIL", ics("BEGIN:VEVENT", "DTSTART;VALUE=DATE:20260704", "RRULE:FREQ=YEARLY;UNTIL=20280101", "END:VEVENT"),
+			[]string{"2026-07-04", "2027-07-04"}, false},
+		{"nth weekday", ics("BEGIN:VEVENT", "DTSTART;VALUE=DATE:20261126", "RRULE:FREQ=YEARLY;BYMONTH=11;BYDAY=4TH;COUNT=2", "END:VEVENT"),
+			[]string{"2026-11-26", "2027-11-25"}, false},
+		{"EXDATE removes occurrences", ics("BEGIN:VEVENT", "DTSTART;VALUE=DATE:20260105", "RRULE:FREQ=WEEKLY;COUNT=3", "EXDATE;VALUE=DATE:20260112", "END:VEVENT"),
+			[]string{"2026-01-05", "2026-01-19"}, false},
+		{"multi-day recurring event", ics("BEGIN:VEVENT", "DTSTART;VALUE=DATE:20261224", "DTEND;VALUE=DATE:20261226", "RRULE:FREQ=YEARLY;COUNT=2", "END:VEVENT"),
+			[]string{"2026-12-24", "2026-12-25", "2027-12-24", "2027-12-25"}, false},
+		{"invalid RRULE", ics("BEGIN:VEVENT", "DTSTART;VALUE=DATE:20260101", "RRULE:FREQ=SOMETIMES", "END:VEVENT"), nil, true},
+		{"invalid date", ics("BEGIN:VEVENT", "DTSTART;VALUE=DATE:2026", "END:VEVENT"), nil, true},
+		{"no events", ics(), nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseICSDates(tt.data, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseICSDates() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseICSDates() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseICSDatesUnboundedRule(t *testing.T) {
+	now := time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC)
+	got, err := parseICSDates(ics("BEGIN:VEVENT", "DTSTART;VALUE=DATE:20250101", "RRULE:FREQ=YEARLY", "END:VEVENT"), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 2025 up to the horizon ten years from now
+	if len(got) != 12 || got[0] != "2025-01-01" || got[len(got)-1] != "2036-01-01" {
+		t.Errorf("parseICSDates() = %v, want every January 1st from 2025 to 2036", got)
+	}
+
+	got, err = parseICSDates(ics("BEGIN:VEVENT", "DTSTART;VALUE=DATE:20260101", "RRULE:FREQ=DAILY", "END:VEVENT"), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != maxICSOccurrences {
+		t.Errorf("daily rule expanded to %d dates, want %d", len(got), maxICSOccurrences)
+	}
+}
+
+// holidays excludes Christmas 2026, a Friday.
+var holidays = models.Calendar{Name: "holidays", Dates: []string{"2026-12-25"}}
+
+func TestApplyCalendarRule(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(day int, hour int) time.Time { return time.Date(2026, 12, day, hour, 0, 0, 0, time.UTC) }
+	tests := []struct {
+		name    string
+		rule    string
+		runTime time.Time
+		loc     *time.Location
+		want    time.Time
+		ok      bool
+	}{
+		{"skip drops an excluded date", models.CalendarSkip, at(25, 9), time.UTC, time.Time{}, false},
+		{"skip keeps other dates", models.CalendarSkip, at(24, 9), time.UTC, at(24, 9), true},
+		{"skip keeps weekends", models.CalendarSkip, at(26, 9), time.UTC, at(26, 9), true},
+		{"dates are read in the schedule's timezone", models.CalendarSkip, at(25, 3), newYork, at(25, 3), true},
+		{"next business day skips the weekend", models.CalendarNextBusinessDay, at(25, 9), time.UTC, at(28, 9), true},
+		{"next business day moves weekend runs", models.CalendarNextBusinessDay, at(26, 9), time.UTC, at(28, 9), true},
+		{"next business day keeps business days", models.CalendarNextBusinessDay, at(24, 9), time.UTC, at(24, 9), true},
+		{"previous business day", models.CalendarPreviousBusinessDay, at(25, 9), time.UTC, at(24, 9), true},
+		{"previous business day from a sunday", models.CalendarPreviousBusinessDay, at(27, 9), time.UTC, at(24, 9), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := applyCalendarRule(holidays, tt.rule, tt.runTime, tt.loc)
+			if ok != tt.ok || !got.Equal(tt.want) {
+				t.Errorf("applyCalendarRule() = %s, %v, want %s, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestNextCalendarRunTime(t *testing.T) {
+	at := func(day int, hour int) time.Time { return time.Date(2026, 12, day, hour, 0, 0, 0, time.UTC) }
+	daily := func(after time.Time) (time.Time, error) {
+		return NextCronTime("0 9 * * *", "UTC", after)
+	}
+	onlyChristmas := func(after time.Time) (time.Time, error) {
+		if after.Before(at(25, 9)) {
+			return at(25, 9), nil
+		}
+		return time.Time{}, ErrScheduleExhausted
+	}
+	tests := []struct {
+		name       string
+		calendar   models.Calendar
+		rule       string
+		after      time.Time
+		occurrence func(time.Time) (time.Time, error)
+		want       time.Time
+		wantErr    error
+	}{
+		{"skip moves to the next allowed occurrence", holidays, models.CalendarSkip, at(24, 10), daily, at(26, 9), nil},
+		{"next business day", holidays, models.CalendarNextBusinessDay, at(24, 10), daily, at(28, 9), nil},
+		{"previous business day cannot go back before after", holidays, models.CalendarPreviousBusinessDay, at(24, 10), daily, at(28, 9), nil},
+		{"previous business day moves the run earlier", holidays, models.CalendarPreviousBusinessDay, at(23, 10), daily, at(24, 9), nil},
+		{"calendar without dates excludes nothing", models.Calendar{Name: "empty"}, models.CalendarSkip, at(24, 10), daily, at(25, 9), nil},
+		{"only run excluded", holidays, models.CalendarSkip, at(24, 10), onlyChristmas, time.Time{}, ErrScheduleExhausted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := nextCalendarRunTime(tt.calendar, tt.rule, "UTC", tt.after, tt.occurrence)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("nextCalendarRunTime() error = %v, want %v", err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("nextCalendarRunTime() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,24 +2,289 @@ package repository
 
 import (
 	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"strconv"
+	"strings"
 	"time"
+	_ "time/tzdata" // embed the IANA database so zones resolve in minimal images
 
 	"github.com/robfig/cron/v3"
 )
 
+const (
+	defaultCronMinInterval = 10 * time.Second
+	cronIntervalSamples    = 100
+)
+
+// cronParser is shared by the API, producer and consumer so that every
+// component accepts the same syntax: standard 5-field cron, an optional
+// leading seconds field, and descriptors such as @hourly or @every 30s.
+var cronParser = cron.NewParser(
+	cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor,
+)
+
+// ParseCron parses a cron expression with the shared parser.
+func ParseCron(CronExpression string) (cron.Schedule, error) {
+	schedule, err := cronParser.Parse(CronExpression)
+	if err != nil {
+		return nil, errors.New("invalid cron expression")
+	}
+	return schedule, nil
+}
+
+// ValidateCron parses the expression, including H tokens, and rejects
+// schedules that fire more often than the configured minimum interval
+// (CRON_MIN_INTERVAL_SECONDS).
 func ValidateCron(CronExpression string) error {
-	_, err := cron.ParseStandard(CronExpression)
+	expanded, err := ExpandHashedCron(CronExpression, "")
 	if err != nil {
-		return errors.New("invalid cron expression")
+		return err
+	}
+	schedule, err := ParseCron(expanded)
+	if err != nil {
+		return err
+	}
+
+	minInterval := cronMinInterval()
+	if interval := shortestCronInterval(schedule); interval > 0 && interval < minInterval {
+		return fmt.Errorf("cron expression fires every %v, minimum interval is %v", interval, minInterval)
+	}
+	return nil
+}
+
+func cronMinInterval() time.Duration {
+	if value := os.Getenv("CRON_MIN_INTERVAL_SECONDS"); value != "" {
+		if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+	return defaultCronMinInterval
+}
+
+// shortestCronInterval returns the smallest gap between consecutive runs,
+// sampled over the upcoming activations in UTC.
+func shortestCronInterval(schedule cron.Schedule) time.Duration {
+	if constant, ok := schedule.(cron.ConstantDelaySchedule); ok {
+		return constant.Delay
+	}
+
+	var shortest time.Duration
+	previous := schedule.Next(time.Now().UTC())
+	for i := 0; i < cronIntervalSamples && !previous.IsZero(); i++ {
+		next := schedule.Next(previous)
+		if next.IsZero() {
+			break
+		}
+		if gap := next.Sub(previous); shortest == 0 || gap < shortest {
+			shortest = gap
+		}
+		previous = next
+	}
+	return shortest
+}
+
+// ValidateTimezone checks that the given IANA zone name can be loaded.
+// An empty timezone is valid and means UTC.
+func ValidateTimezone(Timezone string) error {
+	if _, err := LoadTimezone(Timezone); err != nil {
+		return errors.New("invalid timezone: " + Timezone)
 	}
 	return nil
 }
 
-func CronToTime(CronExpression string) (time.Time, error) {
-	cronData, err := cron.ParseStandard(CronExpression)
+// LoadTimezone resolves an IANA zone name, defaulting to UTC when empty.
+func LoadTimezone(Timezone string) (*time.Location, error) {
+	if Timezone == "" {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(Timezone)
+}
+
+// CronToTime returns the next activation of the cron expression evaluated in
+// the given timezone. Daylight saving transitions are handled as follows for
+// expressions with a fixed hour field:
+//   - a run whose wall-clock time falls into a DST gap (e.g. 01:30 on the
+//     spring-forward day in London) fires once, shifted forward by the length
+//     of the gap (02:30 BST);
+//   - a run whose wall-clock time occurs twice in a DST overlap fires only on
+//     the first occurrence.
+//
+// Expressions with a wildcard hour field (e.g. "*/15 * * * *") follow elapsed
+// time and fire throughout both passes of an overlap.
+func CronToTime(CronExpression string, Timezone string) (time.Time, error) {
+	return NextCronTime(CronExpression, Timezone, time.Now().Add(time.Second))
+}
+
+// NextCronTime returns the first activation of the cron expression strictly
+// after the given instant, evaluated in the given timezone.
+func NextCronTime(CronExpression string, Timezone string, after time.Time) (time.Time, error) {
+	cronData, err := ParseCron(CronExpression)
+	if err != nil {
+		return time.Time{}, err
+	}
+	loc, err := LoadTimezone(Timezone)
 	if err != nil {
-		return time.Time{}, errors.New("invalid cron expression")
+		return time.Time{}, errors.New("invalid timezone: " + Timezone)
+	}
+	nextRunTime := nextInLocation(cronData, loc, after)
+	if nextRunTime.IsZero() {
+		return time.Time{}, errors.New("cron expression has no upcoming run")
+	}
+	return nextRunTime.UTC(), nil
+}
+
+func nextInLocation(schedule cron.Schedule, loc *time.Location, after time.Time) time.Time {
+	spec, ok := schedule.(*cron.SpecSchedule)
+	if !ok || spec.Hour&(1<<63) != 0 {
+		return schedule.Next(after.In(loc))
+	}
+
+	for {
+		next := spec.Next(after.In(loc))
+		if next.IsZero() {
+			return next
+		}
+
+		// Walk the zone transitions between the two instants looking for a
+		// gap in which the schedule would have fired.
+		cursor := after.In(loc)
+		for {
+			_, transition := cursor.ZoneBounds()
+			if transition.IsZero() || !transition.Before(next) {
+				break
+			}
+			if shifted, ok := firedInGap(spec, transition); ok && shifted.After(after) && shifted.Before(next) {
+				return shifted
+			}
+			cursor = transition
+		}
+
+		// Skip the second pass of a repeated wall-clock hour.
+		if isRepeatedWallClock(next) {
+			after = next
+			continue
+		}
+		return next
+	}
+}
+
+// firedInGap reports whether the schedule matches a wall-clock time skipped by
+// the transition, returning the instant shifted past the gap.
+func firedInGap(spec *cron.SpecSchedule, transition time.Time) (time.Time, bool) {
+	_, oldOffset := transition.Add(-time.Second).Zone()
+	_, newOffset := transition.Zone()
+	gap := time.Duration(newOffset-oldOffset) * time.Second
+	if gap <= 0 {
+		return time.Time{}, false
+	}
+
+	wallSpec := *spec
+	wallSpec.Location = time.UTC
+	gapStart := transition.Add(time.Duration(oldOffset) * time.Second).UTC()
+	match := wallSpec.Next(gapStart.Add(-time.Second))
+	if match.IsZero() || !match.Before(gapStart.Add(gap)) {
+		return time.Time{}, false
+	}
+	return transition.Add(match.Sub(gapStart)), true
+}
+
+// isRepeatedWallClock reports whether t lies in the second pass of a DST
+// overlap, i.e. the same wall-clock time already occurred earlier.
+func isRepeatedWallClock(t time.Time) bool {
+	start, _ := t.ZoneBounds()
+	if start.IsZero() {
+		return false
+	}
+	_, oldOffset := start.Add(-time.Second).Zone()
+	_, newOffset := start.Zone()
+	overlap := time.Duration(oldOffset-newOffset) * time.Second
+	return overlap > 0 && t.Sub(start) < overlap
+}
+
+// hashBounds are the ranges an H token resolves into, per cron field. Days of
+// month stop at 28 so hashed schedules run in every month.
+var hashBounds = map[int][2]int{
+	0: {0, 59}, // seconds
+	1: {0, 59}, // minutes
+	2: {0, 23}, // hours
+	3: {1, 28}, // day of month
+	4: {1, 12}, // month
+	5: {0, 6},  // day of week
+}
+
+// ExpandHashedCron replaces Jenkins-style H tokens with deterministic values
+// derived from the seed, so schedules sharing an expression such as
+// "H * * * *" spread over the hour instead of all firing at :00. Supported
+// forms are H, H(a-b), H/n and H(a-b)/n. Descriptors are returned unchanged.
+func ExpandHashedCron(CronExpression string, seed string) (string, error) {
+	if strings.HasPrefix(CronExpression, "@") || !strings.Contains(CronExpression, "H") {
+		return CronExpression, nil
+	}
+
+	fields := strings.Fields(CronExpression)
+	offset := 0
+	switch len(fields) {
+	case 5:
+		offset = 1 // no seconds field
+	case 6:
+	default:
+		return "", errors.New("invalid cron expression")
+	}
+
+	for i, field := range fields {
+		position := i + offset
+		parts := strings.Split(field, ",")
+		for j, part := range parts {
+			expanded, err := expandHashedPart(part, hashBounds[position], cronFieldHash(seed, position))
+			if err != nil {
+				return "", err
+			}
+			parts[j] = expanded
+		}
+		fields[i] = strings.Join(parts, ",")
 	}
-	var nextRunTime = cronData.Next(time.Now().Add(time.Second))
-	return nextRunTime, nil
+	return strings.Join(fields, " "), nil
+}
+
+func expandHashedPart(part string, bounds [2]int, hash uint32) (string, error) {
+	if !strings.HasPrefix(part, "H") {
+		return part, nil
+	}
+
+	low, high := bounds[0], bounds[1]
+	rest := part[1:]
+	if strings.HasPrefix(rest, "(") {
+		end := strings.Index(rest, ")")
+		if end < 0 {
+			return "", errors.New("invalid cron expression")
+		}
+		if _, err := fmt.Sscanf(rest[1:end], "%d-%d", &low, &high); err != nil || low > high || low < bounds[0] || high > bounds[1] {
+			return "", errors.New("invalid cron expression")
+		}
+		rest = rest[end+1:]
+	}
+
+	if rest == "" {
+		return strconv.Itoa(low + int(hash%uint32(high-low+1))), nil
+	}
+	if !strings.HasPrefix(rest, "/") {
+		return "", errors.New("invalid cron expression")
+	}
+	step, err := strconv.Atoi(rest[1:])
+	if err != nil || step <= 0 {
+		return "", errors.New("invalid cron expression")
+	}
+	start := low + int(hash%uint32(step))
+	if start > high {
+		start = low
+	}
+	return fmt.Sprintf("%d-%d/%d", start, high, step), nil
+}
+
+func cronFieldHash(seed string, position int) uint32 {
+	hasher := fnv.New32a()
+	hasher.Write([]byte(seed + "#" + strconv.Itoa(position)))
+	return hasher.Sum32()
 }
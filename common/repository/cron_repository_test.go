@@ -0,0 +1,186 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// In America/New_York, 2025-03-09 02:00 EST jumps to 03:00 EDT (07:00 UTC)
+// and 2025-11-02 02:00 EDT falls back to 01:00 EST (06:00 UTC).
+var (
+	springForward = time.Date(2025, 3, 9, 7, 0, 0, 0, time.UTC)
+	fallBack      = time.Date(2025, 11, 2, 6, 0, 0, 0, time.UTC)
+)
+
+func newYork(t *testing.T) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+func mustSpec(t *testing.T, expression string) *cron.SpecSchedule {
+	t.Helper()
+	schedule, err := ParseCron(expression)
+	if err != nil {
+		t.Fatalf("ParseCron(%q): %v", expression, err)
+	}
+	spec, ok := schedule.(*cron.SpecSchedule)
+	if !ok {
+		t.Fatalf("ParseCron(%q) is not a SpecSchedule", expression)
+	}
+	return spec
+}
+
+func TestNextInLocationAcrossDST(t *testing.T) {
+	loc := newYork(t)
+	tests := []struct {
+		name       string
+		expression string
+		after      time.Time
+		want       time.Time
+	}{
+		{"spring: run in the gap shifts past it", "30 2 * * *", time.Date(2025, 3, 8, 12, 0, 0, 0, loc), time.Date(2025, 3, 9, 7, 30, 0, 0, time.UTC)},
+		{"spring: run at the start of the gap", "0 2 * * *", time.Date(2025, 3, 8, 12, 0, 0, 0, loc), time.Date(2025, 3, 9, 7, 0, 0, 0, time.UTC)},
+		{"spring: gap run fires once", "30 2 * * *", time.Date(2025, 3, 9, 7, 30, 0, 0, time.UTC), time.Date(2025, 3, 10, 6, 30, 0, 0, time.UTC)},
+		{"spring: run before the gap", "30 1 * * *", time.Date(2025, 3, 8, 12, 0, 0, 0, loc), time.Date(2025, 3, 9, 6, 30, 0, 0, time.UTC)},
+		{"spring: run after the gap keeps wall clock", "0 9 * * *", time.Date(2025, 3, 8, 12, 0, 0, 0, loc), time.Date(2025, 3, 9, 13, 0, 0, 0, time.UTC)},
+		{"fall: repeated hour fires on first pass", "30 1 * * *", time.Date(2025, 11, 1, 12, 0, 0, 0, loc), time.Date(2025, 11, 2, 5, 30, 0, 0, time.UTC)},
+		{"fall: second pass is skipped", "30 1 * * *", time.Date(2025, 11, 2, 5, 30, 0, 0, time.UTC), time.Date(2025, 11, 3, 6, 30, 0, 0, time.UTC)},
+		{"fall: run after the overlap keeps wall clock", "0 9 * * *", time.Date(2025, 11, 1, 12, 0, 0, 0, loc), time.Date(2025, 11, 2, 14, 0, 0, 0, time.UTC)},
+		{"fall: wildcard hour follows elapsed time", "*/30 * * * *", time.Date(2025, 11, 2, 5, 45, 0, 0, time.UTC), time.Date(2025, 11, 2, 6, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := ParseCron(tt.expression)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := nextInLocation(schedule, loc, tt.after)
+			if !got.Equal(tt.want) {
+				t.Errorf("nextInLocation(%q, %s) = %s, want %s", tt.expression, tt.after.UTC(), got.UTC(), tt.want)
+			}
+		})
+	}
+}
+
+func TestFiredInGap(t *testing.T) {
+	loc := newYork(t)
+	tests := []struct {
+		name       string
+		expression string
+		transition time.Time
+		want       time.Time
+		ok         bool
+	}{
+		{"matches inside the gap", "30 2 * * *", springForward, time.Date(2025, 3, 9, 7, 30, 0, 0, time.UTC), true},
+		{"matches at the start of the gap", "0 2 * * *", springForward, springForward, true},
+		{"matches outside the gap", "0 9 * * *", springForward, time.Time{}, false},
+		{"matches on another day", "30 2 10 * *", springForward, time.Time{}, false},
+		{"fall back has no gap", "30 1 * * *", fallBack, time.Time{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := mustSpec(t, tt.expression)
+			spec.Location = loc
+			got, ok := firedInGap(spec, tt.transition.In(loc))
+			if ok != tt.ok || !got.Equal(tt.want) {
+				t.Errorf("firedInGap(%q) = %s, %v, want %s, %v", tt.expression, got.UTC(), ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestIsRepeatedWallClock(t *testing.T) {
+	loc := newYork(t)
+	tests := []struct {
+		name string
+		t    time.Time
+		want bool
+	}{
+		{"first pass of 01:30", time.Date(2025, 11, 2, 5, 30, 0, 0, time.UTC), false},
+		{"second pass of 01:00", fallBack, true},
+		{"second pass of 01:30", time.Date(2025, 11, 2, 6, 30, 0, 0, time.UTC), true},
+		{"02:00 EST after the overlap", time.Date(2025, 11, 2, 7, 0, 0, 0, time.UTC), false},
+		{"after spring forward", time.Date(2025, 3, 9, 7, 30, 0, 0, time.UTC), false},
+		{"ordinary day", time.Date(2025, 6, 11, 14, 0, 0, 0, time.UTC), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRepeatedWallClock(tt.t.In(loc)); got != tt.want {
+				t.Errorf("isRepeatedWallClock(%s) = %v, want %v", tt.t, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateCron(t *testing.T) {
+	tests := []struct {
+		name        string
+		minInterval string
+		expression  string
+		wantErr     bool
+	}{
+		{"five fields", "10", "*/5 * * * *", false},
+		{"six fields", "10", "30 0 9 * * 1-5", false},
+		{"six fields at the minimum", "10", "*/10 * * * * *", false},
+		{"six fields below the minimum", "10", "*/5 * * * * *", true},
+		{"every second", "10", "* * * * * *", true},
+		{"seven fields", "10", "0 0 9 * * 1-5 2025", true},
+		{"descriptor", "10", "@hourly", false},
+		{"unknown descriptor", "10", "@fortnightly", true},
+		{"@every above the minimum", "10", "@every 30s", false},
+		{"@every at the minimum", "10", "@every 10s", false},
+		{"@every below the minimum", "10", "@every 5s", true},
+		{"@every without a duration", "10", "@every", true},
+		{"raised minimum", "60", "*/30 * * * * *", true},
+		{"raised minimum, five fields", "60", "* * * * *", false},
+		{"no minimum", "0", "* * * * * *", false},
+		{"invalid setting keeps the default", "soon", "@every 5s", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CRON_MIN_INTERVAL_SECONDS", tt.minInterval)
+			err := ValidateCron(tt.expression)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateCron(%q) error = %v, wantErr %v", tt.expression, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCronDescriptors(t *testing.T) {
+	// A Wednesday
+	after := time.Date(2025, 6, 11, 10, 30, 0, 0, time.UTC)
+	tests := []struct {
+		descriptor string
+		want       time.Time
+	}{
+		{"@yearly", time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"@annually", time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2025, 6, 15, 0, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2025, 6, 12, 0, 0, 0, 0, time.UTC)},
+		{"@midnight", time.Date(2025, 6, 12, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2025, 6, 11, 11, 0, 0, 0, time.UTC)},
+		{"@every 90m", time.Date(2025, 6, 11, 12, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.descriptor, func(t *testing.T) {
+			if err := ValidateCron(tt.descriptor); err != nil {
+				t.Fatalf("ValidateCron(%q): %v", tt.descriptor, err)
+			}
+			got, err := NextCronTime(tt.descriptor, "UTC", after)
+			if err != nil {
+				t.Fatalf("NextCronTime(%q): %v", tt.descriptor, err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("NextCronTime(%q) = %s, want %s", tt.descriptor, got, tt.want)
+			}
+		})
+	}
+}
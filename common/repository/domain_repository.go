@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Sumit189/letItGo/common/database"
+	"github.com/Sumit189/letItGo/common/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrDomainNotFound = errors.New("domain not found")
+
+var domainPattern = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+var VerifiedDomains *mongo.Collection
+
+func InitializeVerifiedDomainsRepository() {
+	VerifiedDomains = database.GetCollection("verifieddomains")
+}
+
+// NormalizeDomain lowercases a host name and strips a trailing dot. Only bare
+// host names with at least two labels are accepted.
+func NormalizeDomain(domain string) (string, error) {
+	domain = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+	if len(domain) > 253 || !domainPattern.MatchString(domain) {
+		return "", errors.New("domain must be a host name such as api.example.com, without scheme, port or path")
+	}
+	return domain, nil
+}
+
+// RequestDomainVerification returns the verification record of the domain,
+// creating it with token if it does not exist yet. An existing record keeps
+// its token so a published TXT record or file stays valid.
+func RequestDomainVerification(ctx context.Context, domain string, token string) (models.VerifiedDomain, error) {
+	now := time.Now()
+	var record models.VerifiedDomain
+	err := VerifiedDomains.FindOneAndUpdate(
+		ctx,
+		bson.M{"domain": domain},
+		bson.M{"$setOnInsert": bson.M{"domain": domain, "token": token, "verified": false, "created_at": now, "updated_at": now}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&record)
+	return record, err
+}
+
+func GetVerifiedDomain(ctx context.Context, domain string) (models.VerifiedDomain, error) {
+	var record models.VerifiedDomain
+	err := VerifiedDomains.FindOne(ctx, bson.M{"domain": domain}).Decode(&record)
+	if err == mongo.ErrNoDocuments {
+		return models.VerifiedDomain{}, ErrDomainNotFound
+	}
+	return record, err
+}
+
+// MarkDomainVerified records a successful verification with the given method.
+// Verifying again renews the expiry, as for webhooks.
+func MarkDomainVerified(ctx context.Context, domain string, method string) (models.VerifiedDomain, error) {
+	now := time.Now()
+	set := bson.M{"verified": true, "method": method, "verified_at": now, "updated_at": now}
+	update := bson.M{"$set": set}
+	if ttl := webhookVerificationTTL(); ttl > 0 {
+		set["expires_at"] = now.Add(ttl)
+	} else {
+		update["$unset"] = bson.M{"expires_at": ""}
+	}
+
+	var record models.VerifiedDomain
+	err := VerifiedDomains.FindOneAndUpdate(
+		ctx,
+		bson.M{"domain": domain},
+		update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&record)
+	if err == mongo.ErrNoDocuments {
+		return models.VerifiedDomain{}, ErrDomainNotFound
+	}
+	return record, err
+}
+
+func ListVerifiedDomains(ctx context.Context) ([]models.VerifiedDomain, error) {
+	cursor, err := VerifiedDomains.Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"domain": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	domains := []models.VerifiedDomain{}
+	if err := cursor.All(ctx, &domains); err != nil {
+		return nil, err
+	}
+	return domains, nil
+}
+
+// DeleteVerifiedDomain removes the verification of a domain. Schedules
+// already created for URLs on it are not affected.
+func DeleteVerifiedDomain(ctx context.Context, domain string) error {
+	result, err := VerifiedDomains.DeleteOne(ctx, bson.M{"domain": domain})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrDomainNotFound
+	}
+	return nil
+}
+
+// isVerifiedDomainURL reports whether the host of webhookURL is covered by an
+// active domain verification, either of the host itself or, for DNS
+// verifications, of one of its parent domains.
+func isVerifiedDomainURL(ctx context.Context, webhookURL string) bool {
+	target, err := url.Parse(webhookURL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") {
+		return false
+	}
+	host, err := NormalizeDomain(target.Hostname())
+	if err != nil {
+		return false
+	}
+
+	labels := strings.Split(host, ".")
+	candidates := make([]string, 0, len(labels)-1)
+	for i := 0; i < len(labels)-1; i++ {
+		candidates = append(candidates, strings.Join(labels[i:], "."))
+	}
+	cursor, err := VerifiedDomains.Find(ctx, bson.M{"domain": bson.M{"$in": candidates}, "verified": true})
+	if err != nil {
+		return false
+	}
+	defer cursor.Close(ctx)
+
+	var domains []models.VerifiedDomain
+	if err := cursor.All(ctx, &domains); err != nil {
+		return false
+	}
+	now := time.Now()
+	for _, domain := range domains {
+		if domain.IsActive(now) && domain.Authorizes(host) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Sumit189/letItGo/common/models"
+	"github.com/redis/go-redis/v9"
+)
+
+const seriesLockPrefix = "series_lock:"
+
+// releaseSeriesLockScript deletes the lock only if it is still held by the
+// given run, so a run that was replaced cannot release its successor's lock.
+var releaseSeriesLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// ValidConcurrencyPolicy reports whether the policy is known. Policies only
+// apply to recurring schedules.
+func ValidConcurrencyPolicy(policy string, recurring bool) error {
+	switch policy {
+	case models.ConcurrencyAllow, models.ConcurrencyForbid, models.ConcurrencyReplace:
+		if !recurring {
+			return errors.New("concurrency_policy applies to recurring schedules only")
+		}
+		return nil
+	}
+	return fmt.Errorf("invalid concurrency_policy: %s", policy)
+}
+
+// AcquireSeriesLock takes the lock of a recurring series for the given run.
+// With takeover set, the lock is taken even if another run holds it.
+func AcquireSeriesLock(ctx context.Context, seriesKey string, runID string, ttl time.Duration, takeover bool) (bool, error) {
+	key := seriesLockPrefix + seriesKey
+	if takeover {
+		if err := RedisClient.Set(ctx, key, runID, ttl).Err(); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+	return RedisClient.SetNX(ctx, key, runID, ttl).Result()
+}
+
+// SeriesLockHolder returns the run currently holding the series lock, or an
+// empty string if the lock is free.
+func SeriesLockHolder(ctx context.Context, seriesKey string) (string, error) {
+	holder, err := RedisClient.Get(ctx, seriesLockPrefix+seriesKey).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	return holder, err
+}
+
+func ReleaseSeriesLock(ctx context.Context, seriesKey string, runID string) error {
+	return releaseSeriesLockScript.Run(ctx, RedisClient, []string{seriesLockPrefix + seriesKey}, runID).Err()
+}
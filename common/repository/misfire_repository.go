@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Sumit189/letItGo/common/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const defaultMisfireTolerance = 5 * time.Minute
+
+// ErrRunExpired is returned when a run is dropped because it could not start
+// within its max_lateness.
+var ErrRunExpired = errors.New("run missed its max_lateness deadline")
+
+// ValidMisfirePolicy reports whether the policy is known. Policies that work
+// with future occurrences are only meaningful for recurring schedules.
+func ValidMisfirePolicy(policy string, recurring bool) error {
+	switch policy {
+	case models.MisfireFireNow, models.MisfireFail:
+		return nil
+	case models.MisfireSkipToNext, models.MisfireFireAllMissed:
+		if !recurring {
+			return fmt.Errorf("misfire_policy %s applies to recurring schedules only", policy)
+		}
+		return nil
+	}
+	return fmt.Errorf("invalid misfire_policy: %s", policy)
+}
+
+// misfireTolerance returns how late a schedule may be picked up before its
+// misfire policy applies. MISFIRE_TOLERANCE_SECONDS sets the default.
+func misfireTolerance(schedule models.Scheduler) time.Duration {
+	if schedule.MisfireToleranceSeconds > 0 {
+		return time.Duration(schedule.MisfireToleranceSeconds) * time.Second
+	}
+	if value := os.Getenv("MISFIRE_TOLERANCE_SECONDS"); value != "" {
+		if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+	return defaultMisfireTolerance
+}
+
+func isMisfired(schedule models.Scheduler, now time.Time) bool {
+	return schedule.NextRunTime != nil && schedule.NextRunTime.Before(now.Add(-misfireTolerance(schedule)))
+}
+
+// handleMisfire applies the schedule's misfire policy and reports whether the
+// late run should still be dispatched.
+func handleMisfire(ctx context.Context, schedule models.Scheduler) (bool, error) {
+	log.Printf("Schedule %s misfired (due %v), applying policy %q", schedule.ID, schedule.NextRunTime, schedule.MisfirePolicy)
+
+	switch schedule.MisfirePolicy {
+	case models.MisfireFail:
+		if !schedule.IsRecurring() {
+			return false, SendToArchive(ctx, schedule, "failed")
+		}
+		// Only the missed run fails; the series goes on
+		if err := recordMissedRun(ctx, schedule, "failed"); err != nil {
+			return false, err
+		}
+		return false, moveToNextRun(ctx, schedule, true)
+	case models.MisfireSkipToNext:
+		if !schedule.IsRecurring() {
+			return false, SendToArchive(ctx, schedule, "skipped")
+		}
+		return false, moveToNextRun(ctx, schedule, false)
+	default:
+		// fire_now and fire_all_missed both run the late occurrence; the
+		// latter also reschedules from the missed time in StartRun.
+		return true, nil
+	}
+}
+
+// recordMissedRun archives the missed run of a series as an execution with
+// the given status.
+func recordMissedRun(ctx context.Context, series models.Scheduler, status string) error {
+	run := seriesExecution(series)
+	run.ID = primitive.NewObjectID().Hex()
+	run.StatusReason = fmt.Sprintf("missed by more than the misfire tolerance of %v", misfireTolerance(series))
+	return SendToArchive(ctx, run, status)
+}
+
+// moveToNextRun moves a series past its missed run to the next one after now.
+// A missed run that was recorded counts toward max_runs. A series without
+// further runs is archived: as completed when its last run was recorded,
+// otherwise as skipped.
+func moveToNextRun(ctx context.Context, schedule models.Scheduler, recorded bool) error {
+	scheduleID, err := primitive.ObjectIDFromHex(schedule.ID)
+	if err != nil {
+		return fmt.Errorf("invalid task ID: %v", err)
+	}
+
+	next := schedule
+	update := bson.M{}
+	if recorded {
+		next.RunCount++
+		update["$inc"] = bson.M{"run_count": 1}
+	}
+	nextRunTime, err := computeNextRunTime(ctx, next, time.Now())
+	if err != nil {
+		if errors.Is(err, ErrScheduleExhausted) {
+			if recorded {
+				return SendToArchive(ctx, next, "completed")
+			}
+			return SendToArchive(ctx, next, "skipped")
+		}
+		return err
+	}
+
+	update["$set"] = bson.M{
+		"next_run_time": nextRunTime,
+		"updated_at":    time.Now(),
+	}
+	_, err = SchedulerCollection.UpdateOne(ctx, bson.M{"_id": scheduleID, "status": "pending"}, update)
+	return err
+}
+
+// ExpireIfLate archives the run as expired, recording the reason, when it
+// would start at the given time after its max_lateness deadline. It reports
+// whether the run expired.
+func ExpireIfLate(ctx context.Context, schedule models.Scheduler, at time.Time) (bool, error) {
+	deadline, ok := schedule.Deadline()
+	if !ok || !at.After(deadline) {
+		return false, nil
+	}
+
+	schedule.StatusReason = fmt.Sprintf("not started within max_lateness of %ds (deadline %s)", schedule.MaxLatenessSeconds, deadline.UTC().Format(time.RFC3339))
+	log.Printf("Schedule %s expired: %s", schedule.ID, schedule.StatusReason)
+	return true, SendToArchive(ctx, schedule, "expired")
+}
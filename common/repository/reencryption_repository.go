@@ -0,0 +1,215 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Sumit189/letItGo/common/database"
+	"github.com/Sumit189/letItGo/common/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// reencryptionCheckpointID is the document in the migrations collection that
+// records how far an interrupted re-encryption got.
+const reencryptionCheckpointID = "payload_reencryption"
+
+// ReencryptionOptions controls a run of ReencryptPayloads.
+type ReencryptionOptions struct {
+	BatchSize int  // Documents written back per bulk write
+	Rate      int  // Maximum documents scanned per second, 0 for no limit
+	DryRun    bool // Decrypt and count, but write nothing
+	Restart   bool // Ignore the checkpoint of an interrupted run
+	Force     bool // Also re-encrypt payloads already under the current key
+}
+
+// ReencryptionProgress counts the documents of one collection handled so far
+// in this run.
+type ReencryptionProgress struct {
+	Collection  string        `json:"collection"`
+	Total       int64         `json:"total"`       // Documents with a payload when the run started
+	Scanned     int64         `json:"scanned"`     // Documents read, including those resumed past
+	Reencrypted int64         `json:"reencrypted"` // Payloads written back under the current key
+	Current     int64         `json:"current"`     // Payloads already under the current key
+	Conflicts   int64         `json:"conflicts"`   // Payloads changed by another writer meanwhile
+	Failed      int64         `json:"failed"`      // Payloads that could not be decrypted
+	Elapsed     time.Duration `json:"elapsed"`
+}
+
+type encryptedDocument struct {
+	ID      interface{} `bson:"_id"`
+	Payload string      `bson:"payload"`
+}
+
+func migrationCollection() *mongo.Collection {
+	return database.GetCollection("migrations")
+}
+
+// ReencryptPayloads streams through the schedulers and archives collections
+// in _id order and re-encrypts every payload that is not under the current
+// key, writing back in batches. After each batch the last _id is saved, so an
+// interrupted run resumes where it stopped; the checkpoint is removed once
+// both collections are done. A payload is only replaced if it is unchanged
+// since it was read, so running alongside the API and consumer is safe.
+// report is called after every batch.
+func ReencryptPayloads(ctx context.Context, opts ReencryptionOptions, report func(ReencryptionProgress)) ([]ReencryptionProgress, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 500
+	}
+	if opts.Restart && !opts.DryRun {
+		if _, err := migrationCollection().DeleteOne(ctx, bson.M{"_id": reencryptionCheckpointID}); err != nil {
+			return nil, err
+		}
+	}
+
+	var checkpoint bson.M
+	if !opts.Restart {
+		err := migrationCollection().FindOne(ctx, bson.M{"_id": reencryptionCheckpointID}).Decode(&checkpoint)
+		if err != nil && err != mongo.ErrNoDocuments {
+			return nil, err
+		}
+	}
+
+	var results []ReencryptionProgress
+	for _, collection := range []*mongo.Collection{SchedulerCollection, ArchiveCollection} {
+		progress, err := reencryptCollection(ctx, collection, checkpoint[collection.Name()], opts, report)
+		results = append(results, progress)
+		if err != nil {
+			return results, err
+		}
+	}
+
+	if !opts.DryRun {
+		if _, err := migrationCollection().DeleteOne(ctx, bson.M{"_id": reencryptionCheckpointID}); err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
+
+func reencryptCollection(ctx context.Context, collection *mongo.Collection, lastID interface{}, opts ReencryptionOptions, report func(ReencryptionProgress)) (ReencryptionProgress, error) {
+	start := time.Now()
+	progress := ReencryptionProgress{Collection: collection.Name()}
+
+	withPayload := bson.M{"payload": bson.M{"$nin": []interface{}{"", nil}}}
+	total, err := collection.CountDocuments(ctx, withPayload)
+	if err != nil {
+		return progress, err
+	}
+	progress.Total = total
+
+	filter := withPayload
+	if lastID != nil {
+		filter = bson.M{"payload": withPayload["payload"], "_id": bson.M{"$gt": lastID}}
+		if progress.Scanned, err = collection.CountDocuments(ctx, bson.M{"payload": withPayload["payload"], "_id": bson.M{"$lte": lastID}}); err != nil {
+			return progress, err
+		}
+		log.Printf("Resuming re-encryption of %s after %v", collection.Name(), lastID)
+	}
+
+	cursor, err := collection.Find(ctx, filter, options.Find().
+		SetSort(bson.M{"_id": 1}).
+		SetProjection(bson.M{"payload": 1}).
+		SetBatchSize(int32(opts.BatchSize)))
+	if err != nil {
+		return progress, err
+	}
+	defer cursor.Close(ctx)
+
+	resumedFrom := progress.Scanned
+	var writes []mongo.WriteModel
+	flush := func(lastID interface{}) error {
+		if len(writes) > 0 && !opts.DryRun {
+			result, err := collection.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+			if err != nil {
+				return fmt.Errorf("failed to write re-encrypted payloads of %s: %w", collection.Name(), err)
+			}
+			progress.Reencrypted += result.ModifiedCount
+			progress.Conflicts += int64(len(writes)) - result.ModifiedCount
+		} else if opts.DryRun {
+			progress.Reencrypted += int64(len(writes))
+		}
+		writes = writes[:0]
+
+		if !opts.DryRun && lastID != nil {
+			_, err := migrationCollection().UpdateOne(
+				ctx,
+				bson.M{"_id": reencryptionCheckpointID},
+				bson.M{"$set": bson.M{collection.Name(): lastID, "updated_at": time.Now()}},
+				options.Update().SetUpsert(true),
+			)
+			if err != nil {
+				return fmt.Errorf("failed to save re-encryption checkpoint: %w", err)
+			}
+		}
+		progress.Elapsed = time.Since(start)
+		if report != nil {
+			report(progress)
+		}
+		return nil
+	}
+
+	var last interface{}
+	for cursor.Next(ctx) {
+		var doc encryptedDocument
+		if err := cursor.Decode(&doc); err != nil {
+			return progress, err
+		}
+		last = doc.ID
+		progress.Scanned++
+
+		stale, err := utils.NeedsReencryption(doc.Payload)
+		if err != nil {
+			return progress, fmt.Errorf("failed to determine the current key: %w", err)
+		}
+		if opts.Force || stale {
+			payload, err := utils.Reencrypt(doc.Payload)
+			if err != nil {
+				progress.Failed++
+				log.Printf("Failed to re-encrypt payload of %s %v: %v", collection.Name(), doc.ID, err)
+			} else {
+				writes = append(writes, mongo.NewUpdateOneModel().
+					SetFilter(bson.M{"_id": doc.ID, "payload": doc.Payload}).
+					SetUpdate(bson.M{"$set": bson.M{"payload": payload}}))
+			}
+		} else {
+			progress.Current++
+		}
+
+		if (progress.Scanned-resumedFrom)%int64(opts.BatchSize) == 0 {
+			if err := flush(last); err != nil {
+				return progress, err
+			}
+		}
+		if err := throttle(ctx, start, progress.Scanned-resumedFrom, opts.Rate); err != nil {
+			return progress, err
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return progress, err
+	}
+	return progress, flush(last)
+}
+
+// throttle sleeps until scanning count documents since start stays within
+// rate documents per second.
+func throttle(ctx context.Context, start time.Time, count int64, rate int) error {
+	if rate <= 0 {
+		return nil
+	}
+	wait := time.Duration(count)*time.Second/time.Duration(rate) - time.Since(start)
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,296 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/teambition/rrule-go"
+)
+
+const (
+	rruleSamples = 100
+	// maxRRuleCursors bounds the cursor cache; it is emptied when full.
+	maxRRuleCursors = 1024
+)
+
+// RRule is a parsed RFC 5545 recurrence: the RRULE itself together with its
+// DTSTART and EXDATE properties. The RRULE is expanded by rrule-go.
+type RRule struct {
+	cursor  *rruleCursor
+	exDates []time.Time
+	exDays  []string
+}
+
+// ParseRRule parses an iCalendar recurrence given as DTSTART, RRULE and EXDATE
+// lines. Floating times are interpreted in the given timezone.
+func ParseRRule(text string, timezone string) (*RRule, error) {
+	loc, err := LoadTimezone(timezone)
+	if err != nil {
+		return nil, errors.New("invalid timezone: " + timezone)
+	}
+
+	rule := &RRule{}
+	var dtStart time.Time
+	var ruleText string
+	for _, line := range strings.FieldsFunc(text, func(r rune) bool { return r == '\n' || r == '\r' }) {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			// A bare rule such as FREQ=DAILY;BYHOUR=9
+			name, value = "RRULE", line
+		}
+		property, params, _ := strings.Cut(name, ";")
+		property = strings.ToUpper(property)
+
+		switch property {
+		case "DTSTART":
+			if dtStart, err = parseICSTime(value, params, loc); err != nil {
+				return nil, fmt.Errorf("invalid DTSTART: %v", err)
+			}
+		case "RRULE":
+			ruleText = value
+		case "EXDATE":
+			for _, exValue := range strings.Split(value, ",") {
+				if strings.Contains(strings.ToUpper(params), "VALUE=DATE") && !strings.Contains(exValue, "T") {
+					day, err := time.Parse("20060102", exValue)
+					if err != nil {
+						return nil, fmt.Errorf("invalid EXDATE: %s", exValue)
+					}
+					rule.exDays = append(rule.exDays, day.Format(time.DateOnly))
+					continue
+				}
+				exDate, err := parseICSTime(exValue, params, loc)
+				if err != nil {
+					return nil, fmt.Errorf("invalid EXDATE: %v", err)
+				}
+				rule.exDates = append(rule.exDates, exDate)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported rrule property: %s", property)
+		}
+	}
+
+	if ruleText == "" {
+		return nil, errors.New("missing RRULE")
+	}
+	if dtStart.IsZero() {
+		return nil, errors.New("missing DTSTART")
+	}
+	options, err := parseRRuleOptions(ruleText, dtStart, loc)
+	if err != nil {
+		return nil, err
+	}
+	expansion, err := rrule.NewRRule(*options)
+	if err != nil {
+		return nil, err
+	}
+	rule.cursor = cachedRRuleCursor(text+"\x00"+timezone, expansion)
+	return rule, nil
+}
+
+// parseRRuleOptions parses the RRULE value. On top of rrule-go's checks,
+// INTERVAL and COUNT must be positive, COUNT and UNTIL exclude each other and
+// a date UNTIL includes the whole day.
+func parseRRuleOptions(ruleText string, dtStart time.Time, loc *time.Location) (*rrule.ROption, error) {
+	ruleText = strings.ToUpper(strings.TrimSpace(ruleText))
+	parts := map[string]string{}
+	for _, part := range strings.Split(ruleText, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid rrule part: %s", part)
+		}
+		parts[key] = value
+	}
+	for _, key := range []string{"INTERVAL", "COUNT"} {
+		if value, ok := parts[key]; ok {
+			if number, err := strconv.Atoi(value); err != nil || number < 1 {
+				return nil, fmt.Errorf("%s must be a positive integer", key)
+			}
+		}
+	}
+	if _, hasCount := parts["COUNT"]; hasCount {
+		if _, hasUntil := parts["UNTIL"]; hasUntil {
+			return nil, errors.New("COUNT and UNTIL cannot both be set")
+		}
+	}
+
+	options, err := rrule.StrToROptionInLocation(ruleText, loc)
+	if err != nil {
+		return nil, err
+	}
+	if options.Freq == rrule.WEEKLY || options.Freq > rrule.MONTHLY {
+		for _, day := range options.Byweekday {
+			if day.N() != 0 {
+				return nil, errors.New("numbered BYDAY values require FREQ=MONTHLY or FREQ=YEARLY")
+			}
+		}
+	}
+	if until := parts["UNTIL"]; until != "" && !strings.Contains(until, "T") {
+		options.Until = options.Until.AddDate(0, 0, 1).Add(-time.Second)
+	}
+	options.Dtstart = dtStart
+	return options, nil
+}
+
+func parseICSTime(value string, params string, loc *time.Location) (time.Time, error) {
+	if _, tzid, ok := strings.Cut(params, "TZID="); ok {
+		tzid, _, _ = strings.Cut(tzid, ";")
+		zone, err := time.LoadLocation(tzid)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("unknown TZID %s", tzid)
+		}
+		loc = zone
+	}
+
+	switch {
+	case strings.HasSuffix(value, "Z"):
+		return time.Parse("20060102T150405Z", value)
+	case strings.Contains(value, "T"):
+		return time.ParseInLocation("20060102T150405", value, loc)
+	default:
+		return time.ParseInLocation("20060102", value, loc)
+	}
+}
+
+// NormalizeRRule validates the recurrence and adds a DTSTART of the current
+// minute when none is given, so every later run expands from the same start.
+func NormalizeRRule(text string, timezone string, now time.Time) (string, error) {
+	if !strings.Contains(strings.ToUpper(text), "DTSTART") {
+		loc, err := LoadTimezone(timezone)
+		if err != nil {
+			return "", errors.New("invalid timezone: " + timezone)
+		}
+		dtStart := now.In(loc).Truncate(time.Minute).Format("20060102T150405")
+		if !strings.Contains(strings.ToUpper(text), "RRULE:") {
+			text = "RRULE:" + strings.TrimSpace(text)
+		}
+		text = "DTSTART:" + dtStart + "\n" + strings.TrimSpace(text)
+	}
+	return text, ValidateRRule(text, timezone)
+}
+
+// ValidateRRule parses the recurrence and applies the same minimum interval
+// as cron expressions.
+func ValidateRRule(text string, timezone string) error {
+	rule, err := ParseRRule(text, timezone)
+	if err != nil {
+		return err
+	}
+
+	minInterval := cronMinInterval()
+	previous, ok := rule.After(time.Now())
+	if !ok {
+		return errors.New("rrule has no future occurrences")
+	}
+	for i := 0; i < rruleSamples; i++ {
+		next, found := rule.After(previous)
+		if !found {
+			break
+		}
+		if gap := next.Sub(previous); gap < minInterval {
+			return fmt.Errorf("rrule fires every %v, minimum interval is %v", gap, minInterval)
+		}
+		previous = next
+	}
+	return nil
+}
+
+// NextRRuleTime returns the first occurrence strictly after the given instant,
+// or ErrScheduleExhausted once COUNT or UNTIL is reached.
+func NextRRuleTime(text string, timezone string, after time.Time) (time.Time, error) {
+	rule, err := ParseRRule(text, timezone)
+	if err != nil {
+		return time.Time{}, err
+	}
+	next, ok := rule.After(after)
+	if !ok {
+		return time.Time{}, ErrScheduleExhausted
+	}
+	return next.UTC(), nil
+}
+
+// After returns the first occurrence strictly after t that is not excluded.
+func (r *RRule) After(t time.Time) (time.Time, bool) {
+	for {
+		next, ok := r.cursor.after(t)
+		if !ok {
+			return time.Time{}, false
+		}
+		if !r.excluded(next) {
+			return next, true
+		}
+		t = next
+	}
+}
+
+func (r *RRule) excluded(candidate time.Time) bool {
+	for _, exDate := range r.exDates {
+		if exDate.Equal(candidate) {
+			return true
+		}
+	}
+	day := candidate.Format(time.DateOnly)
+	for _, exDay := range r.exDays {
+		if exDay == day {
+			return true
+		}
+	}
+	return false
+}
+
+// rruleCursor is an iterator over a recurrence, positioned after the last
+// occurrence asked for. Series ask for ever later occurrences, so a cached
+// cursor only expands the occurrences since the previous lookup instead of
+// every occurrence since DTSTART, which matters for long COUNT rules.
+type rruleCursor struct {
+	mu    sync.Mutex
+	rule  *rrule.RRule
+	next  rrule.Next
+	floor time.Time // Every occurrence up to floor has been passed
+	head  time.Time // Next occurrence, zero once the rule is exhausted
+}
+
+var (
+	rruleCursorsMu sync.Mutex
+	rruleCursors   = map[string]*rruleCursor{}
+)
+
+// cachedRRuleCursor returns the cursor of the recurrence identified by key,
+// creating it for rule if needed.
+func cachedRRuleCursor(key string, rule *rrule.RRule) *rruleCursor {
+	rruleCursorsMu.Lock()
+	defer rruleCursorsMu.Unlock()
+	if cursor, ok := rruleCursors[key]; ok {
+		return cursor
+	}
+	if len(rruleCursors) >= maxRRuleCursors {
+		rruleCursors = map[string]*rruleCursor{}
+	}
+	cursor := &rruleCursor{rule: rule}
+	rruleCursors[key] = cursor
+	return cursor
+}
+
+// after returns the first occurrence strictly after t, restarting from
+// DTSTART only when t lies before the cursor.
+func (c *rruleCursor) after(t time.Time) (time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.next == nil || t.Before(c.floor) {
+		c.next = c.rule.Iterator()
+		c.floor = time.Time{}
+		c.head, _ = c.next()
+	}
+	for !c.head.IsZero() && !c.head.After(t) {
+		c.floor = c.head
+		c.head, _ = c.next()
+	}
+	return c.head, !c.head.IsZero()
+}
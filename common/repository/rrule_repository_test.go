@@ -0,0 +1,231 @@
+package repository
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// occurrences returns up to n occurrences of the recurrence as local
+// "2006-01-02 15:04:05" strings, following After from before DTSTART.
+func occurrences(t *testing.T, text string, n int) []string {
+	t.Helper()
+	rule, err := ParseRRule(text, "America/New_York")
+	if err != nil {
+		t.Fatalf("ParseRRule(%q): %v", text, err)
+	}
+	var result []string
+	after := time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC)
+	for len(result) < n {
+		next, ok := rule.After(after)
+		if !ok {
+			break
+		}
+		result = append(result, next.Format("2006-01-02 15:04:05"))
+		after = next
+	}
+	return result
+}
+
+// Examples from RFC 5545, section 3.8.5.3, in America/New_York.
+func TestRRuleRFC5545Examples(t *testing.T) {
+	tests := []struct {
+		name string
+		rule string
+		n    int
+		want []string
+	}{
+		{
+			name: "daily for 10 occurrences",
+			rule: "DTSTART;TZID=America/New_York:19970902T090000\nRRULE:FREQ=DAILY;COUNT=10",
+			n:    20,
+			want: []string{"1997-09-02 09:00:00", "1997-09-03 09:00:00", "1997-09-04 09:00:00", "1997-09-05 09:00:00", "1997-09-06 09:00:00", "1997-09-07 09:00:00", "1997-09-08 09:00:00", "1997-09-09 09:00:00", "1997-09-10 09:00:00", "1997-09-11 09:00:00"},
+		},
+		{
+			name: "every 10 days, 5 occurrences",
+			rule: "DTSTART;TZID=America/New_York:19970902T090000\nRRULE:FREQ=DAILY;INTERVAL=10;COUNT=5",
+			n:    10,
+			want: []string{"1997-09-02 09:00:00", "1997-09-12 09:00:00", "1997-09-22 09:00:00", "1997-10-02 09:00:00", "1997-10-12 09:00:00"},
+		},
+		{
+			name: "weekly on Tuesday and Thursday for five weeks",
+			rule: "DTSTART;TZID=America/New_York:19970902T090000\nRRULE:FREQ=WEEKLY;UNTIL=19971007T000000Z;WKST=SU;BYDAY=TU,TH",
+			n:    20,
+			want: []string{"1997-09-02 09:00:00", "1997-09-04 09:00:00", "1997-09-09 09:00:00", "1997-09-11 09:00:00", "1997-09-16 09:00:00", "1997-09-18 09:00:00", "1997-09-23 09:00:00", "1997-09-25 09:00:00", "1997-09-30 09:00:00", "1997-10-02 09:00:00"},
+		},
+		{
+			name: "every other week on Monday, Wednesday and Friday",
+			rule: "DTSTART;TZID=America/New_York:19970901T090000\nRRULE:FREQ=WEEKLY;INTERVAL=2;UNTIL=19971224T000000Z;WKST=SU;BYDAY=MO,WE,FR",
+			n:    30,
+			want: []string{"1997-09-01 09:00:00", "1997-09-03 09:00:00", "1997-09-05 09:00:00", "1997-09-15 09:00:00", "1997-09-17 09:00:00", "1997-09-19 09:00:00", "1997-09-29 09:00:00", "1997-10-01 09:00:00", "1997-10-03 09:00:00", "1997-10-13 09:00:00", "1997-10-15 09:00:00", "1997-10-17 09:00:00", "1997-10-27 09:00:00", "1997-10-29 09:00:00", "1997-10-31 09:00:00", "1997-11-10 09:00:00", "1997-11-12 09:00:00", "1997-11-14 09:00:00", "1997-11-24 09:00:00", "1997-11-26 09:00:00", "1997-11-28 09:00:00", "1997-12-08 09:00:00", "1997-12-10 09:00:00", "1997-12-12 09:00:00", "1997-12-22 09:00:00"},
+		},
+		{
+			name: "monthly on the first Friday for 10 occurrences",
+			rule: "DTSTART;TZID=America/New_York:19970905T090000\nRRULE:FREQ=MONTHLY;COUNT=10;BYDAY=1FR",
+			n:    20,
+			want: []string{"1997-09-05 09:00:00", "1997-10-03 09:00:00", "1997-11-07 09:00:00", "1997-12-05 09:00:00", "1998-01-02 09:00:00", "1998-02-06 09:00:00", "1998-03-06 09:00:00", "1998-04-03 09:00:00", "1998-05-01 09:00:00", "1998-06-05 09:00:00"},
+		},
+		{
+			name: "monthly on the second-to-last Monday for 6 months",
+			rule: "DTSTART;TZID=America/New_York:19970922T090000\nRRULE:FREQ=MONTHLY;COUNT=6;BYDAY=-2MO",
+			n:    10,
+			want: []string{"1997-09-22 09:00:00", "1997-10-20 09:00:00", "1997-11-17 09:00:00", "1997-12-22 09:00:00", "1998-01-19 09:00:00", "1998-02-16 09:00:00"},
+		},
+		{
+			name: "monthly on the third-to-the-last day",
+			rule: "DTSTART;TZID=America/New_York:19970928T090000\nRRULE:FREQ=MONTHLY;BYMONTHDAY=-3",
+			n:    6,
+			want: []string{"1997-09-28 09:00:00", "1997-10-29 09:00:00", "1997-11-28 09:00:00", "1997-12-29 09:00:00", "1998-01-29 09:00:00", "1998-02-26 09:00:00"},
+		},
+		{
+			name: "every third year on the 1st, 100th and 200th day",
+			rule: "DTSTART;TZID=America/New_York:19970101T090000\nRRULE:FREQ=YEARLY;INTERVAL=3;COUNT=10;BYYEARDAY=1,100,200",
+			n:    20,
+			want: []string{"1997-01-01 09:00:00", "1997-04-10 09:00:00", "1997-07-19 09:00:00", "2000-01-01 09:00:00", "2000-04-09 09:00:00", "2000-07-18 09:00:00", "2003-01-01 09:00:00", "2003-04-10 09:00:00", "2003-07-19 09:00:00", "2006-01-01 09:00:00"},
+		},
+		{
+			name: "Monday of week number 20",
+			rule: "DTSTART;TZID=America/New_York:19970512T090000\nRRULE:FREQ=YEARLY;BYWEEKNO=20;BYDAY=MO",
+			n:    3,
+			want: []string{"1997-05-12 09:00:00", "1998-05-11 09:00:00", "1999-05-17 09:00:00"},
+		},
+		{
+			name: "every Friday the 13th, excluding DTSTART",
+			rule: "DTSTART;TZID=America/New_York:19970902T090000\nEXDATE;TZID=America/New_York:19970902T090000\nRRULE:FREQ=MONTHLY;BYDAY=FR;BYMONTHDAY=13",
+			n:    5,
+			want: []string{"1998-02-13 09:00:00", "1998-03-13 09:00:00", "1998-11-13 09:00:00", "1999-08-13 09:00:00", "2000-10-13 09:00:00"},
+		},
+		{
+			name: "last work day of the month",
+			rule: "DTSTART;TZID=America/New_York:19970929T090000\nRRULE:FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1",
+			n:    7,
+			want: []string{"1997-09-30 09:00:00", "1997-10-31 09:00:00", "1997-11-28 09:00:00", "1997-12-31 09:00:00", "1998-01-30 09:00:00", "1998-02-27 09:00:00", "1998-03-31 09:00:00"},
+		},
+		{
+			name: "every 3 hours from 9 to 5 on a specific day",
+			rule: "DTSTART;TZID=America/New_York:19970902T090000\nRRULE:FREQ=HOURLY;INTERVAL=3;UNTIL=19970902T210000Z",
+			n:    10,
+			want: []string{"1997-09-02 09:00:00", "1997-09-02 12:00:00", "1997-09-02 15:00:00"},
+		},
+		{
+			name: "every 15 minutes for 6 occurrences",
+			rule: "DTSTART;TZID=America/New_York:19970902T090000\nRRULE:FREQ=MINUTELY;INTERVAL=15;COUNT=6",
+			n:    10,
+			want: []string{"1997-09-02 09:00:00", "1997-09-02 09:15:00", "1997-09-02 09:30:00", "1997-09-02 09:45:00", "1997-09-02 10:00:00", "1997-09-02 10:15:00"},
+		},
+		{
+			name: "WKST changes which days an INTERVAL=2 week covers, MO",
+			rule: "DTSTART;TZID=America/New_York:19970805T090000\nRRULE:FREQ=WEEKLY;INTERVAL=2;COUNT=4;BYDAY=TU,SU;WKST=MO",
+			n:    10,
+			want: []string{"1997-08-05 09:00:00", "1997-08-10 09:00:00", "1997-08-19 09:00:00", "1997-08-24 09:00:00"},
+		},
+		{
+			name: "WKST changes which days an INTERVAL=2 week covers, SU",
+			rule: "DTSTART;TZID=America/New_York:19970805T090000\nRRULE:FREQ=WEEKLY;INTERVAL=2;COUNT=4;BYDAY=TU,SU;WKST=SU",
+			n:    10,
+			want: []string{"1997-08-05 09:00:00", "1997-08-17 09:00:00", "1997-08-19 09:00:00", "1997-08-31 09:00:00"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := occurrences(t, tt.rule, tt.n)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("occurrences:\n got %v\nwant %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRRuleExtensions(t *testing.T) {
+	tests := []struct {
+		name string
+		rule string
+		n    int
+		want []string
+	}{
+		{
+			name: "weekly with BYMONTHDAY",
+			rule: "DTSTART;TZID=America/New_York:19970902T090000\nRRULE:FREQ=WEEKLY;BYMONTHDAY=1",
+			n:    3,
+			want: []string{"1997-10-01 09:00:00", "1997-11-01 09:00:00", "1997-12-01 09:00:00"},
+		},
+		{
+			name: "secondly",
+			rule: "DTSTART;TZID=America/New_York:19970902T090000\nRRULE:FREQ=SECONDLY;INTERVAL=30;COUNT=3",
+			n:    10,
+			want: []string{"1997-09-02 09:00:00", "1997-09-02 09:00:30", "1997-09-02 09:01:00"},
+		},
+		{
+			name: "date UNTIL includes the whole day",
+			rule: "DTSTART;TZID=America/New_York:19970902T090000\nRRULE:FREQ=DAILY;UNTIL=19970904",
+			n:    10,
+			want: []string{"1997-09-02 09:00:00", "1997-09-03 09:00:00", "1997-09-04 09:00:00"},
+		},
+		{
+			name: "EXDATE with VALUE=DATE excludes the whole day",
+			rule: "DTSTART;TZID=America/New_York:19970902T090000\nEXDATE;VALUE=DATE:19970903\nRRULE:FREQ=DAILY;COUNT=3",
+			n:    10,
+			want: []string{"1997-09-02 09:00:00", "1997-09-04 09:00:00"},
+		},
+		{
+			name: "floating DTSTART in the schedule timezone across DST",
+			rule: "DTSTART:20251101T090000\nRRULE:FREQ=DAILY;COUNT=3",
+			n:    10,
+			want: []string{"2025-11-01 09:00:00", "2025-11-02 09:00:00", "2025-11-03 09:00:00"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := occurrences(t, tt.rule, tt.n)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("occurrences:\n got %v\nwant %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRRuleLongCount(t *testing.T) {
+	rule, err := ParseRRule("DTSTART:20250101T000000Z\nRRULE:FREQ=MINUTELY;COUNT=100000", "UTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dtStart := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	last := dtStart.Add(99999 * time.Minute)
+
+	if next, ok := rule.After(last.Add(-time.Minute)); !ok || !next.Equal(last) {
+		t.Fatalf("After(99998th) = %v, %v, want the 100000th occurrence %v", next, ok, last)
+	}
+	if next, ok := rule.After(last); ok {
+		t.Fatalf("After(100000th) = %v, want no further occurrence", next)
+	}
+	// The cursor moves back when asked for an earlier occurrence
+	if next, ok := rule.After(dtStart); !ok || !next.Equal(dtStart.Add(time.Minute)) {
+		t.Fatalf("After(DTSTART) = %v, %v, want the second occurrence", next, ok)
+	}
+}
+
+func TestParseRRuleErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		rule string
+	}{
+		{"missing DTSTART", "RRULE:FREQ=DAILY"},
+		{"missing RRULE", "DTSTART:20250101T090000"},
+		{"missing FREQ", "DTSTART:20250101T090000\nRRULE:COUNT=3"},
+		{"COUNT and UNTIL", "DTSTART:20250101T090000\nRRULE:FREQ=DAILY;COUNT=3;UNTIL=20250110"},
+		{"zero INTERVAL", "DTSTART:20250101T090000\nRRULE:FREQ=DAILY;INTERVAL=0"},
+		{"zero COUNT", "DTSTART:20250101T090000\nRRULE:FREQ=DAILY;COUNT=0"},
+		{"numbered BYDAY with WEEKLY", "DTSTART:20250101T090000\nRRULE:FREQ=WEEKLY;BYDAY=1MO"},
+		{"out of range BYMONTHDAY", "DTSTART:20250101T090000\nRRULE:FREQ=MONTHLY;BYMONTHDAY=32"},
+		{"unsupported property", "DTSTART:20250101T090000\nRDATE:20250105T090000\nRRULE:FREQ=DAILY"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseRRule(tt.rule, "UTC"); err == nil {
+				t.Errorf("ParseRRule(%q) succeeded, want an error", tt.rule)
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"math/rand/v2"
 	"reflect"
 	"time"
 
@@ -19,6 +20,24 @@ import (
 
 var SchedulerCollection *mongo.Collection
 
+// ErrScheduleExhausted is returned when a recurring schedule has reached its
+// end_at or max_runs bound and has no further runs.
+var ErrScheduleExhausted = errors.New("recurring schedule has no further runs")
+
+var ErrScheduleNotFound = errors.New("schedule not found")
+
+// ErrInvalidScheduleState is returned when a schedule cannot be changed in its
+// current status, e.g. a run that is already executing.
+var ErrInvalidScheduleState = errors.New("operation not allowed in the schedule's current status")
+
+// ErrRunCancelled is returned when a run of a series is not retried because
+// the series was cancelled while it was executing.
+var ErrRunCancelled = errors.New("run cancelled together with its series")
+
+// SeriesCancelledReason is the status_reason of runs cancelled together with
+// their series.
+const SeriesCancelledReason = "series cancelled"
+
 func InitializeSchedulerRepository() {
 	SchedulerCollection = database.GetCollection("schedulers")
 }
@@ -38,15 +57,11 @@ func Schedule(ctx context.Context, scheduler models.Scheduler) (models.Scheduler
 	}
 	newScheduler.UpdatedAt = time.Now()
 
-	if scheduler.CronExpression != "" {
-		runTimeBasedOnCron, err := CronToTime(scheduler.CronExpression)
-		if err != nil {
-			return models.Scheduler{}, errors.New("invalid cron expression")
-		}
-		newScheduler.NextRunTime = &runTimeBasedOnCron
-	} else {
-		newScheduler.NextRunTime = scheduler.ScheduleTime
+	nextRunTime, err := computeNextRunTime(ctx, *newScheduler, time.Now().Add(time.Second))
+	if err != nil {
+		return models.Scheduler{}, err
 	}
+	newScheduler.NextRunTime = nextRunTime
 
 	insertedDoc, err := SchedulerCollection.InsertOne(ctx, newScheduler)
 	if err != nil {
@@ -63,6 +78,210 @@ func Schedule(ctx context.Context, scheduler models.Scheduler) (models.Scheduler
 	return *newScheduler, nil
 }
 
+// computeNextRunTime returns when the schedule should next be dispatched: its
+// schedule_time, or for recurring schedules the next run strictly after the
+// given instant. Jitter is applied to both, but never moves a run past
+// end_at.
+func computeNextRunTime(ctx context.Context, scheduler models.Scheduler, after time.Time) (*time.Time, error) {
+	nextRunTime := scheduler.ScheduleTime
+	if scheduler.IsRecurring() {
+		next, err := nextRecurringRunTime(ctx, scheduler, after)
+		if err != nil {
+			return nil, err
+		}
+		nextRunTime = &next
+	}
+
+	// Spread runs that would otherwise fire in the same second
+	if scheduler.JitterSeconds > 0 && nextRunTime != nil {
+		jitter := time.Duration(rand.Int64N(int64(scheduler.JitterSeconds)+1)) * time.Second
+		jittered := nextRunTime.Add(jitter)
+		if scheduler.EndAt != nil && jittered.After(*scheduler.EndAt) && !nextRunTime.After(*scheduler.EndAt) {
+			jittered = *scheduler.EndAt
+		}
+		nextRunTime = &jittered
+	}
+	return nextRunTime, nil
+}
+
+// nextRecurringRunTime returns the next run of a recurring schedule after the
+// given instant, honouring its start_at, end_at and max_runs bounds and its
+// business calendar.
+func nextRecurringRunTime(ctx context.Context, scheduler models.Scheduler, after time.Time) (time.Time, error) {
+	if scheduler.MaxRuns > 0 && scheduler.RunCount >= scheduler.MaxRuns {
+		return time.Time{}, ErrScheduleExhausted
+	}
+	if scheduler.StartAt != nil && scheduler.StartAt.After(after) {
+		after = scheduler.StartAt.Add(-time.Nanosecond)
+	}
+
+	nextOccurrence, err := occurrenceFunc(scheduler)
+	if err != nil {
+		return time.Time{}, err
+	}
+	var nextRunTime time.Time
+	if scheduler.Calendar != "" {
+		calendar, err := FindCalendar(ctx, scheduler.Calendar)
+		if err != nil {
+			return time.Time{}, err
+		}
+		nextRunTime, err = nextCalendarRunTime(calendar, scheduler.CalendarRule, scheduler.Timezone, after, nextOccurrence)
+		if err != nil {
+			return time.Time{}, err
+		}
+	} else {
+		nextRunTime, err = nextOccurrence(after)
+		if err != nil {
+			return time.Time{}, err
+		}
+	}
+	if scheduler.EndAt != nil && nextRunTime.After(*scheduler.EndAt) {
+		return time.Time{}, ErrScheduleExhausted
+	}
+	return nextRunTime, nil
+}
+
+// occurrenceFunc returns a function yielding the schedule's first occurrence
+// strictly after an instant, from its triggers, its RRULE or its cron
+// expression.
+func occurrenceFunc(scheduler models.Scheduler) (func(time.Time) (time.Time, error), error) {
+	if len(scheduler.Triggers) > 0 {
+		return triggersOccurrenceFunc(scheduler)
+	}
+	if scheduler.RRule != "" {
+		rule, err := ParseRRule(scheduler.RRule, scheduler.Timezone)
+		if err != nil {
+			return nil, err
+		}
+		return func(after time.Time) (time.Time, error) {
+			next, ok := rule.After(after)
+			if !ok {
+				return time.Time{}, ErrScheduleExhausted
+			}
+			return next.UTC(), nil
+		}, nil
+	}
+
+	cronExpression, err := ExpandHashedCron(scheduler.CronExpression, hashSeed(scheduler))
+	if err != nil {
+		return nil, err
+	}
+	return func(after time.Time) (time.Time, error) {
+		return NextCronTime(cronExpression, scheduler.Timezone, after)
+	}, nil
+}
+
+// triggersOccurrenceFunc combines the triggers of a schedule: its next
+// occurrence is the earliest one due across all of them, so triggers firing
+// at the same instant run once.
+func triggersOccurrenceFunc(scheduler models.Scheduler) (func(time.Time) (time.Time, error), error) {
+	occurrences := make([]func(time.Time) (time.Time, error), 0, len(scheduler.Triggers))
+	for _, trigger := range scheduler.Triggers {
+		if trigger.ScheduleTime != nil {
+			runTime := trigger.ScheduleTime.UTC()
+			occurrences = append(occurrences, func(after time.Time) (time.Time, error) {
+				if !runTime.After(after) {
+					return time.Time{}, ErrScheduleExhausted
+				}
+				return runTime, nil
+			})
+			continue
+		}
+
+		rule := scheduler
+		rule.Triggers = nil
+		rule.CronExpression = trigger.CronExpression
+		rule.RRule = trigger.RRule
+		occurrence, err := occurrenceFunc(rule)
+		if err != nil {
+			return nil, err
+		}
+		occurrences = append(occurrences, occurrence)
+	}
+
+	return func(after time.Time) (time.Time, error) {
+		var earliest time.Time
+		for _, occurrence := range occurrences {
+			next, err := occurrence(after)
+			if errors.Is(err, ErrScheduleExhausted) {
+				continue
+			}
+			if err != nil {
+				return time.Time{}, err
+			}
+			if earliest.IsZero() || next.Before(earliest) {
+				earliest = next
+			}
+		}
+		if earliest.IsZero() {
+			return time.Time{}, ErrScheduleExhausted
+		}
+		return earliest, nil
+	}, nil
+}
+
+// ValidateTriggers applies the minimum interval of cron expressions to the
+// combined triggers of a schedule, so rules that are each slow enough cannot
+// fire close together.
+func ValidateTriggers(scheduler models.Scheduler) error {
+	nextOccurrence, err := triggersOccurrenceFunc(scheduler)
+	if err != nil {
+		return err
+	}
+
+	minInterval := cronMinInterval()
+	previous, err := nextOccurrence(time.Now())
+	for i := 0; i < cronIntervalSamples && err == nil; i++ {
+		var next time.Time
+		next, err = nextOccurrence(previous)
+		if err != nil {
+			break
+		}
+		if gap := next.Sub(previous); gap < minInterval {
+			return fmt.Errorf("triggers fire %v apart, minimum interval is %v", gap, minInterval)
+		}
+		previous = next
+	}
+	if err != nil && !errors.Is(err, ErrScheduleExhausted) {
+		return err
+	}
+	return nil
+}
+
+// PreviewRuns returns up to count upcoming runs of the scheduler without
+// storing it. Jitter is random per run and is not applied.
+func PreviewRuns(ctx context.Context, scheduler models.Scheduler, count int) ([]time.Time, error) {
+	if !scheduler.IsRecurring() {
+		if scheduler.ScheduleTime == nil {
+			return []time.Time{}, nil
+		}
+		return []time.Time{scheduler.ScheduleTime.UTC()}, nil
+	}
+
+	runs := []time.Time{}
+	after := time.Now().Add(time.Second)
+	for len(runs) < count {
+		nextRunTime, err := nextRecurringRunTime(ctx, scheduler, after)
+		if err != nil {
+			if errors.Is(err, ErrScheduleExhausted) {
+				break
+			}
+			return nil, err
+		}
+		runs = append(runs, nextRunTime)
+		scheduler.RunCount++
+		after = nextRunTime
+	}
+	return runs, nil
+}
+
+// hashSeed derives the value H tokens are hashed from. It only uses fields
+// taken from the request, so every run of a series and a preview of the same
+// request resolve to the same offset.
+func hashSeed(scheduler models.Scheduler) string {
+	return scheduler.MethodType + " " + scheduler.WebhookURL + " " + scheduler.HashKey
+}
+
 func FetchPending(ctx context.Context, limit int64) ([]models.Scheduler, error) {
 	currentTime := time.Now()
 	timeWindowEnd := currentTime.Add(1 * time.Minute)
@@ -86,7 +305,8 @@ func FetchPending(ctx context.Context, limit int64) ([]models.Scheduler, error)
 
 	findOptions := options.Find()
 	findOptions.SetLimit(limit)
-	findOptions.SetSort(bson.M{"next_run_time": 1})
+	// Under backlog, higher priorities are fetched first
+	findOptions.SetSort(bson.D{{Key: "priority", Value: -1}, {Key: "next_run_time", Value: 1}})
 
 	cursor, err := SchedulerCollection.Find(ctx, filter, findOptions)
 	if err != nil {
@@ -106,6 +326,24 @@ func FetchPending(ctx context.Context, limit int64) ([]models.Scheduler, error)
 		return nil, err
 	}
 
+	// Pending schedules picked up later than their tolerance are handled by
+	// their misfire policy instead of being dispatched blindly
+	dueTasks := []models.Scheduler{}
+	for _, task := range tasks {
+		if task.Status == "pending" && isMisfired(task, currentTime) {
+			dispatch, err := handleMisfire(ctx, task)
+			if err != nil {
+				log.Printf("Error handling misfire for schedule %s: %v", task.ID, err)
+				continue
+			}
+			if !dispatch {
+				continue
+			}
+		}
+		dueTasks = append(dueTasks, task)
+	}
+	tasks = dueTasks
+
 	updateModels := []mongo.WriteModel{}
 
 	for _, task := range tasks {
@@ -162,35 +400,211 @@ func UpdateSchedulerStatus(ctx context.Context, schedule models.Scheduler, statu
 		return fmt.Errorf("no scheduler found with ID %v", schedule.ID)
 	}
 
-	// Handle cron rescheduling for "in-progress" status
-	if status == "in-progress" && schedule.CronExpression != "" {
-		return rescheduleCronJob(ctx, schedulerID)
+	return nil
+}
+
+// FindSchedule returns a schedule by ID, looking in the archive once it has
+// finished.
+func FindSchedule(ctx context.Context, id string) (models.Scheduler, error) {
+	scheduleID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return models.Scheduler{}, ErrScheduleNotFound
 	}
 
-	return nil
+	var schedule models.Scheduler
+	for _, collection := range []*mongo.Collection{SchedulerCollection, ArchiveCollection} {
+		err = collection.FindOne(ctx, bson.M{"_id": scheduleID}).Decode(&schedule)
+		if err == nil {
+			return schedule, nil
+		}
+		if err != mongo.ErrNoDocuments {
+			return models.Scheduler{}, err
+		}
+	}
+	return models.Scheduler{}, ErrScheduleNotFound
 }
 
-func rescheduleCronJob(ctx context.Context, schedulerID primitive.ObjectID) error {
-	// Find the updated schedule by ID using the provided context
-	var updatedSchedule models.Scheduler
-	err := SchedulerCollection.FindOne(ctx, bson.M{"_id": schedulerID}).Decode(&updatedSchedule)
+// CancelSchedule archives a schedule that has not started executing as
+// cancelled. For a recurring series this ends the whole series, including
+// runs waiting for a retry; runs that are executing are flagged so they are
+// not retried.
+func CancelSchedule(ctx context.Context, id string) (models.Scheduler, error) {
+	var schedule models.Scheduler
+	err := transitionSchedule(ctx, id, []string{"pending", "processing", "paused", "blocked"}, "cancelled", &schedule)
 	if err != nil {
-		return fmt.Errorf("failed to find updated schedule with ID %v: %w", schedulerID.Hex(), err)
+		return models.Scheduler{}, err
 	}
+	if err := SendToArchive(ctx, schedule, "cancelled"); err != nil {
+		return models.Scheduler{}, err
+	}
+	if schedule.IsRecurring() {
+		if err := cancelSeriesRuns(ctx, schedule.ID); err != nil {
+			return models.Scheduler{}, err
+		}
+	}
+	schedule.Status = "cancelled"
+	return schedule, nil
+}
 
-	// Update the schedule for the next run
-	updatedSchedule.Status = "pending"
-	updatedSchedule.UpdatedAt = time.Now()
-	updatedSchedule.ID = "" // Reset ID to avoid conflicts
+// cancelSeriesRuns cancels the unfinished runs of a series. Runs that have
+// not started again are archived as cancelled; executing runs get
+// cancel_requested and are archived once their current attempt ends.
+func cancelSeriesRuns(ctx context.Context, seriesID string) error {
+	_, err := SchedulerCollection.UpdateMany(
+		ctx,
+		bson.M{"series_id": seriesID, "status": "in-progress"},
+		bson.M{"$set": bson.M{"cancel_requested": true, "updated_at": time.Now()}},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to flag running runs of series %s: %w", seriesID, err)
+	}
 
-	// Schedule the updated task with context support
-	if _, err := Schedule(ctx, updatedSchedule); err != nil {
-		return fmt.Errorf("failed to schedule cron for updated schedule with ID %v: %w", schedulerID.Hex(), err)
+	cursor, err := SchedulerCollection.Find(ctx, bson.M{
+		"series_id": seriesID,
+		"status":    bson.M{"$in": []string{"pending", "processing", "paused", "blocked"}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to find runs of series %s: %w", seriesID, err)
+	}
+	var runs []models.Scheduler
+	if err := cursor.All(ctx, &runs); err != nil {
+		return err
 	}
 
+	for _, run := range runs {
+		var cancelled models.Scheduler
+		err := transitionSchedule(ctx, run.ID, []string{"pending", "processing", "paused", "blocked"}, "cancelled", &cancelled)
+		if errors.Is(err, ErrInvalidScheduleState) || errors.Is(err, ErrScheduleNotFound) {
+			// The run was picked up or finished meanwhile
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		cancelled.StatusReason = SeriesCancelledReason
+		if err := SendToArchive(ctx, cancelled, "cancelled"); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// PauseSchedule stops a schedule from being dispatched until it is resumed.
+func PauseSchedule(ctx context.Context, id string) (models.Scheduler, error) {
+	var schedule models.Scheduler
+	if err := transitionSchedule(ctx, id, []string{"pending", "processing"}, "paused", &schedule); err != nil {
+		return models.Scheduler{}, err
+	}
+	schedule.Status = "paused"
+	return schedule, nil
+}
+
+// ResumeSchedule makes a paused schedule pending again. Recurring series
+// continue with their next run after now; runs missed while paused are not
+// replayed. A one-time schedule whose time passed is handled by its misfire
+// policy.
+func ResumeSchedule(ctx context.Context, id string) (models.Scheduler, error) {
+	schedule, err := findActiveSchedule(ctx, id, []string{"paused"})
+	if err != nil {
+		return models.Scheduler{}, err
+	}
+
+	if schedule.IsRecurring() {
+		nextRunTime, err := computeNextRunTime(ctx, schedule, time.Now().Add(time.Second))
+		if err != nil {
+			if errors.Is(err, ErrScheduleExhausted) {
+				return schedule, SendToArchive(ctx, schedule, "completed")
+			}
+			return models.Scheduler{}, err
+		}
+		schedule.NextRunTime = nextRunTime
+	}
+
+	scheduleID, _ := primitive.ObjectIDFromHex(schedule.ID)
+	result, err := SchedulerCollection.UpdateOne(
+		ctx,
+		bson.M{"_id": scheduleID, "status": "paused"},
+		bson.M{"$set": bson.M{
+			"status":        "pending",
+			"next_run_time": schedule.NextRunTime,
+			"updated_at":    time.Now(),
+		}},
+	)
+	if err != nil {
+		return models.Scheduler{}, err
+	}
+	if result.MatchedCount == 0 {
+		return models.Scheduler{}, ErrInvalidScheduleState
+	}
+	schedule.Status = "pending"
+	return schedule, nil
+}
+
+// UpdateSchedule replaces a pending or paused schedule, recomputing its next
+// run from the updated timing fields. The status and run count are kept.
+func UpdateSchedule(ctx context.Context, schedule models.Scheduler) (models.Scheduler, error) {
+	scheduleID, err := primitive.ObjectIDFromHex(schedule.ID)
+	if err != nil {
+		return models.Scheduler{}, ErrScheduleNotFound
+	}
+
+	nextRunTime, err := computeNextRunTime(ctx, schedule, time.Now().Add(time.Second))
+	if err != nil {
+		return models.Scheduler{}, err
+	}
+	schedule.NextRunTime = nextRunTime
+	schedule.UpdatedAt = time.Now()
+
+	replacement := schedule
+	replacement.ID = ""
+	result, err := SchedulerCollection.ReplaceOne(
+		ctx,
+		bson.M{"_id": scheduleID, "status": bson.M{"$in": []string{"pending", "paused"}}},
+		replacement,
+	)
+	if err != nil {
+		return models.Scheduler{}, err
+	}
+	if result.MatchedCount == 0 {
+		return models.Scheduler{}, ErrInvalidScheduleState
+	}
+	return schedule, nil
+}
+
+// findActiveSchedule returns a schedule that is still in the schedulers
+// collection with one of the given statuses.
+func findActiveSchedule(ctx context.Context, id string, statuses []string) (models.Scheduler, error) {
+	schedule, err := FindSchedule(ctx, id)
+	if err != nil {
+		return models.Scheduler{}, err
+	}
+	for _, status := range statuses {
+		if schedule.Status == status {
+			return schedule, nil
+		}
+	}
+	return models.Scheduler{}, fmt.Errorf("%w: schedule is %s", ErrInvalidScheduleState, schedule.Status)
+}
+
+// transitionSchedule atomically moves a schedule from one of the given
+// statuses to the new one, decoding the schedule as it was before the change.
+func transitionSchedule(ctx context.Context, id string, from []string, to string, schedule *models.Scheduler) error {
+	if _, err := findActiveSchedule(ctx, id, from); err != nil {
+		return err
+	}
+
+	scheduleID, _ := primitive.ObjectIDFromHex(id)
+	err := SchedulerCollection.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": scheduleID, "status": bson.M{"$in": from}},
+		bson.M{"$set": bson.M{"status": to, "updated_at": time.Now()}},
+	).Decode(schedule)
+	if err == mongo.ErrNoDocuments {
+		return ErrInvalidScheduleState
+	}
+	return err
+}
+
 func UpdateRetries(ctx context.Context, schedule models.Scheduler) error {
 	scheduleID, err := primitive.ObjectIDFromHex(schedule.ID)
 
@@ -204,9 +618,20 @@ func UpdateRetries(ctx context.Context, schedule models.Scheduler) error {
 	}
 
 	nextRetryTime := time.Now().Add(time.Duration(schedule.RetryAfterInSeconds) * time.Second)
-	_, err = SchedulerCollection.UpdateOne(
+
+	// A retry that would start after the deadline expires the run right away
+	expired, err := ExpireIfLate(ctx, schedule, nextRetryTime)
+	if err != nil {
+		log.Printf("Error sending to archive: %v", err)
+	}
+	if expired {
+		return ErrRunExpired
+	}
+
+	// A run whose series was cancelled meanwhile is not retried
+	result, err := SchedulerCollection.UpdateOne(
 		ctx,
-		bson.M{"_id": scheduleID},
+		bson.M{"_id": scheduleID, "cancel_requested": bson.M{"$ne": true}},
 		bson.M{
 			"$inc": bson.M{"retries": 1},
 			"$set": bson.M{
@@ -219,15 +644,20 @@ func UpdateRetries(ctx context.Context, schedule models.Scheduler) error {
 	if err != nil {
 		return errors.New("error updating retries")
 	}
+	if result.MatchedCount == 0 {
+		return ErrRunCancelled
+	}
 	return nil
 }
 
+// ExpireSchedules fails runs that were dispatched but got stuck. Late pending
+// schedules are handled by their misfire policy in FetchPending instead.
 func ExpireSchedules(ctx context.Context) error {
 	findOptions := bson.M{
 		"$or": []bson.M{
 			{
 				"status": bson.M{
-					"$nin": []string{"completed", "failed"},
+					"$in": []string{"processing", "in-progress"},
 				},
 				"next_run_time": bson.M{
 					"$gte": time.Now().Add(-10 * time.Minute),
@@ -254,8 +684,10 @@ func ExpireSchedules(ctx context.Context) error {
 		return err
 	}
 
-	// Mark all dead schedules as failed
+	// Mark all dead runs as failed. A recurring series whose run was never
+	// started goes back to pending, leaving the late run to its misfire policy.
 	updateModels := []mongo.WriteModel{}
+	deadRuns := []models.Scheduler{}
 
 	for _, schedule := range deadSchedules {
 		objectID, err := primitive.ObjectIDFromHex(schedule.ID)
@@ -264,6 +696,14 @@ func ExpireSchedules(ctx context.Context) error {
 		}
 		updateFilter := bson.M{"_id": objectID}
 		update := bson.M{"$set": bson.M{"status": "failed"}}
+		if schedule.CancelRequested {
+			update = bson.M{"$set": bson.M{"status": "cancelled"}}
+			deadRuns = append(deadRuns, schedule)
+		} else if schedule.IsRecurring() {
+			update = bson.M{"$set": bson.M{"status": "pending"}}
+		} else {
+			deadRuns = append(deadRuns, schedule)
+		}
 
 		updateModel := mongo.NewUpdateOneModel().
 			SetFilter(updateFilter).
@@ -277,8 +717,13 @@ func ExpireSchedules(ctx context.Context) error {
 			log.Printf("Bulk update error to picked: %v", err)
 		}
 
-		for _, schedule := range deadSchedules {
-			err = SendToArchive(ctx, schedule, "failed")
+		for _, schedule := range deadRuns {
+			status := "failed"
+			if schedule.CancelRequested {
+				status = "cancelled"
+				schedule.StatusReason = SeriesCancelledReason
+			}
+			err = SendToArchive(ctx, schedule, status)
 			if err != nil {
 				log.Printf("Error sending to archive: %v", err)
 			}
@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Sumit189/letItGo/common/models"
+)
+
+func TestJitterNeverPassesEndAt(t *testing.T) {
+	endAt := time.Date(2030, 1, 1, 9, 0, 30, 0, time.UTC)
+	scheduler := models.Scheduler{
+		CronExpression: "0 9 * * *",
+		Timezone:       "UTC",
+		EndAt:          &endAt,
+		JitterSeconds:  3600,
+	}
+	after := time.Date(2030, 1, 1, 8, 0, 0, 0, time.UTC)
+	for i := 0; i < 200; i++ {
+		next, err := computeNextRunTime(context.Background(), scheduler, after)
+		if err != nil {
+			t.Fatalf("computeNextRunTime: %v", err)
+		}
+		if next.Before(endAt.Add(-30*time.Second)) || next.After(endAt) {
+			t.Fatalf("run at %s, want between 09:00:00 and end_at %s", next, endAt)
+		}
+	}
+}
+
+func TestValidateTriggersChecksCombinedInterval(t *testing.T) {
+	t.Setenv("CRON_MIN_INTERVAL_SECONDS", "10")
+	tests := []struct {
+		name     string
+		triggers []models.Trigger
+		wantErr  bool
+	}{
+		{"far apart", []models.Trigger{{CronExpression: "0 0 9 * * *"}, {CronExpression: "0 0 17 * * *"}}, false},
+		{"same instant runs once", []models.Trigger{{CronExpression: "0 9 * * *"}, {CronExpression: "0 9 * * 1-5"}}, false},
+		{"too close together", []models.Trigger{{CronExpression: "0 0 9 * * *"}, {CronExpression: "5 0 9 * * *"}}, true},
+		{"rrule next to cron", []models.Trigger{{CronExpression: "0 0 9 * * *"}, {RRule: "FREQ=DAILY;BYHOUR=9;BYMINUTE=0;BYSECOND=3"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateTriggers(models.Scheduler{Triggers: tt.triggers, Timezone: "UTC"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateTriggers() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Sumit189/letItGo/common/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrRunAlreadyStarted is returned when a recurring series is no longer in
+// the processing state, because another worker started the run or the series
+// was paused or cancelled meanwhile.
+var ErrRunAlreadyStarted = errors.New("series run already started or no longer due")
+
+// StartRun starts the due run of a recurring series. The series document keeps
+// its ID and is moved to its next run in place; the run itself is recorded as
+// a one-time child execution with the given ID, which is returned and goes
+// through the usual retry and archive flow. A series that reached its bounds
+// is archived as completed.
+func StartRun(ctx context.Context, series models.Scheduler, runID string) (models.Scheduler, error) {
+	childID, err := primitive.ObjectIDFromHex(runID)
+	if err != nil {
+		return models.Scheduler{}, fmt.Errorf("invalid run ID: %v", err)
+	}
+
+	next, exhausted, err := advanceSeries(ctx, series, true)
+	if err != nil {
+		return models.Scheduler{}, err
+	}
+
+	child := seriesExecution(series)
+	child.Status = "in-progress"
+	document, err := toDocumentWithID(child, childID)
+	if err != nil {
+		return models.Scheduler{}, err
+	}
+	if _, err := SchedulerCollection.InsertOne(ctx, document); err != nil {
+		return models.Scheduler{}, fmt.Errorf("failed to record run of series %v: %w", series.ID, err)
+	}
+	child.ID = runID
+
+	if exhausted {
+		log.Printf("Recurring schedule %s completed after %d runs", series.ID, next.RunCount)
+		if err := SendToArchive(ctx, next, "completed"); err != nil {
+			log.Printf("Error archiving completed series %s: %v", series.ID, err)
+		}
+	}
+	return child, nil
+}
+
+// SkipRun moves a recurring series past its due run without executing it,
+// e.g. because the previous run still holds the series under the forbid
+// concurrency policy. The run is archived as skipped and does not count
+// toward max_runs.
+func SkipRun(ctx context.Context, series models.Scheduler, reason string) error {
+	next, exhausted, err := advanceSeries(ctx, series, false)
+	if err != nil {
+		return err
+	}
+
+	run := seriesExecution(series)
+	run.ID = primitive.NewObjectID().Hex()
+	run.StatusReason = reason
+	if err := SendToArchive(ctx, run, "skipped"); err != nil {
+		return err
+	}
+
+	if exhausted {
+		log.Printf("Recurring schedule %s completed after %d runs", series.ID, next.RunCount)
+		return SendToArchive(ctx, next, "completed")
+	}
+	return nil
+}
+
+// advanceSeries moves a series that is being processed on to its next run,
+// counting the due run toward max_runs when counted is set. It returns the
+// series as updated and whether it has no further runs.
+func advanceSeries(ctx context.Context, series models.Scheduler, counted bool) (models.Scheduler, bool, error) {
+	seriesID, err := primitive.ObjectIDFromHex(series.ID)
+	if err != nil {
+		return models.Scheduler{}, false, fmt.Errorf("invalid task ID: %v", err)
+	}
+
+	// Catching up on missed runs continues from the missed occurrence
+	// instead of jumping to the next one after now
+	after := time.Now().Add(time.Second)
+	if series.MisfirePolicy == models.MisfireFireAllMissed && series.NextRunTime != nil && series.NextRunTime.Before(after) {
+		after = *series.NextRunTime
+	}
+
+	next := series
+	update := bson.M{}
+	if counted {
+		next.RunCount++
+		update["$inc"] = bson.M{"run_count": 1}
+	}
+	nextRunTime, err := computeNextRunTime(ctx, next, after)
+	exhausted := errors.Is(err, ErrScheduleExhausted)
+	if err != nil && !exhausted {
+		return models.Scheduler{}, false, fmt.Errorf("failed to compute next run of series %v: %w", series.ID, err)
+	}
+
+	set := bson.M{"status": "pending", "updated_at": time.Now()}
+	if exhausted {
+		set["status"] = "completed"
+	} else {
+		set["next_run_time"] = nextRunTime
+	}
+	update["$set"] = set
+	result, err := SchedulerCollection.UpdateOne(ctx, bson.M{"_id": seriesID, "status": "processing"}, update)
+	if err != nil {
+		return models.Scheduler{}, false, fmt.Errorf("failed to update series with ID %v: %w", series.ID, err)
+	}
+	if result.MatchedCount == 0 {
+		return models.Scheduler{}, false, ErrRunAlreadyStarted
+	}
+	return next, exhausted, nil
+}
+
+// ListExecutions returns the runs of a series, newest first: runs that are
+// still executing or waiting for a retry, followed by archived ones.
+func ListExecutions(ctx context.Context, seriesID string, limit int64) ([]models.Scheduler, error) {
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "schedule_time", Value: -1}, {Key: "_id", Value: -1}}).
+		SetLimit(limit)
+
+	executions := []models.Scheduler{}
+	for _, collection := range []*mongo.Collection{SchedulerCollection, ArchiveCollection} {
+		cursor, err := collection.Find(ctx, bson.M{"series_id": seriesID}, findOptions)
+		if err != nil {
+			return nil, err
+		}
+		var runs []models.Scheduler
+		err = cursor.All(ctx, &runs)
+		cursor.Close(ctx)
+		if err != nil {
+			return nil, err
+		}
+		executions = append(executions, runs...)
+		if int64(len(executions)) >= limit {
+			return executions[:limit], nil
+		}
+		findOptions.SetLimit(limit - int64(len(executions)))
+	}
+	return executions, nil
+}
+
+// seriesExecution returns the one-time execution of the series' due run,
+// without an ID or status.
+func seriesExecution(series models.Scheduler) models.Scheduler {
+	child := series
+	child.ID = ""
+	child.SeriesID = series.ID
+	child.ScheduleTime = series.NextRunTime
+	child.CronExpression = ""
+	child.RRule = ""
+	child.Triggers = nil
+	child.StartAt = nil
+	child.EndAt = nil
+	child.MaxRuns = 0
+	child.Status = ""
+	child.RunCount = 0
+	child.Retries = 0
+	child.WebhookRetryCount = 0
+	child.CreatedAt = time.Now()
+	child.UpdatedAt = time.Now()
+	return child
+}
+
+// toDocumentWithID encodes a schedule for the schedulers collection under an
+// ObjectID chosen by the caller.
+func toDocumentWithID(schedule models.Scheduler, id primitive.ObjectID) (bson.M, error) {
+	schedule.ID = ""
+	data, err := bson.Marshal(schedule)
+	if err != nil {
+		return nil, err
+	}
+	var document bson.M
+	if err := bson.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+	document["_id"] = id
+	return document, nil
+}
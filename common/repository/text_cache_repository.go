@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	textCachePrefix   = "text_cache:"
+	textCacheStatsKey = "text_cache:stats"
+
+	defaultTextCacheTTL = 24 * time.Hour
+	// textCacheBucket is how long a resolved time is reused. Phrases such as
+	// "in 10 minutes" resolve to a different time every minute.
+	textCacheBucket = time.Minute
+)
+
+// TextCacheStats counts lookups of LLM results in the text cache.
+type TextCacheStats struct {
+	Hits    int64   `json:"hits"`
+	Misses  int64   `json:"misses"`
+	HitRate float64 `json:"hit_rate"`
+}
+
+// textCacheTTL returns how long cron results are cached, from
+// NL_CACHE_TTL_SECONDS. Zero disables the cache.
+func textCacheTTL() time.Duration {
+	if value := os.Getenv("NL_CACHE_TTL_SECONDS"); value != "" {
+		if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+	return defaultTextCacheTTL
+}
+
+// textCacheKey identifies an LLM result by the normalized text, the timezone
+// and locale it was read in and the provider that produced it. Results with
+// times are additionally keyed on the minute they were resolved in; cron
+// expressions do not depend on the current time.
+func textCacheKey(text string, locale string, now time.Time, bucketed bool) string {
+	parts := []string{normalizeText(text), now.Location().String(), strings.ToLower(locale)}
+	if LLMProvider != nil {
+		parts = append(parts, LLMProvider.Name())
+	}
+	if bucketed {
+		parts = append(parts, now.UTC().Truncate(textCacheBucket).Format(time.RFC3339))
+	}
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return textCachePrefix + hex.EncodeToString(sum[:])
+}
+
+// cachedTextSchedule looks up an earlier LLM result for text, first among
+// cron expressions and then among results containing a time resolved in the
+// current minute. Results that no longer validate are ignored.
+func cachedTextSchedule(ctx context.Context, text string, locale string, now time.Time) (TextSchedule, bool) {
+	if RedisClient == nil || textCacheTTL() == 0 {
+		return TextSchedule{}, false
+	}
+
+	for _, bucketed := range []bool{false, true} {
+		data, err := RedisClient.Get(ctx, textCacheKey(text, locale, now, bucketed)).Bytes()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			log.Printf("Error reading text cache: %v", err)
+			return TextSchedule{}, false
+		}
+		var schedule TextSchedule
+		if err := json.Unmarshal(data, &schedule); err != nil {
+			continue
+		}
+		if validateTextResult(schedule, now) != nil {
+			continue
+		}
+		countTextCacheLookup(ctx, "hits")
+		return schedule, true
+	}
+	countTextCacheLookup(ctx, "misses")
+	return TextSchedule{}, false
+}
+
+// cacheTextSchedule stores an LLM result. Errors are logged; the cache is
+// only an optimisation.
+func cacheTextSchedule(ctx context.Context, text string, locale string, now time.Time, schedule TextSchedule) {
+	ttl := textCacheTTL()
+	if RedisClient == nil || ttl == 0 {
+		return
+	}
+	if schedule.dependsOnNow() && ttl > textCacheBucket {
+		ttl = textCacheBucket
+	}
+
+	data, err := json.Marshal(schedule)
+	if err != nil {
+		return
+	}
+	if err := RedisClient.Set(ctx, textCacheKey(text, locale, now, schedule.dependsOnNow()), data, ttl).Err(); err != nil {
+		log.Printf("Error writing text cache: %v", err)
+	}
+}
+
+func countTextCacheLookup(ctx context.Context, field string) {
+	if err := RedisClient.HIncrBy(ctx, textCacheStatsKey, field, 1).Err(); err != nil {
+		log.Printf("Error counting text cache %s: %v", field, err)
+	}
+}
+
+// GetTextCacheStats returns the hit and miss counts of the text cache,
+// shared by all API instances.
+func GetTextCacheStats(ctx context.Context) (TextCacheStats, error) {
+	values, err := RedisClient.HGetAll(ctx, textCacheStatsKey).Result()
+	if err != nil {
+		return TextCacheStats{}, err
+	}
+	var stats TextCacheStats
+	stats.Hits, _ = strconv.ParseInt(values["hits"], 10, 64)
+	stats.Misses, _ = strconv.ParseInt(values["misses"], 10, 64)
+	if total := stats.Hits + stats.Misses; total > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(total)
+	}
+	return stats, nil
+}
@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+// maxTextPhraseWords is the longest phrase, in words, in textLocalePhrases.
+const maxTextPhraseWords = 3
+
+// textLocalePhrases translate the words of a supported language into the
+// English phrases understood by parseTextRules. An empty translation drops
+// the word. Anything not listed is kept as is, so numbers and clock times
+// pass through.
+var textLocalePhrases = map[string]map[string]string{
+	"es": {
+		"en": "in", "dentro de": "in", "un": "a", "una": "a",
+		"minuto": "minute", "minutos": "minutes", "hora": "hour", "horas": "hours",
+		"día": "day", "dia": "day", "días": "days", "dias": "days", "semana": "week", "semanas": "weeks",
+		"hoy": "today", "esta noche": "tonight", "mañana": "tomorrow", "manana": "tomorrow",
+		"pasado mañana": "day after tomorrow", "pasado manana": "day after tomorrow",
+		"de la mañana": "am", "de la manana": "am", "de la tarde": "pm", "de la noche": "pm",
+		"a las": "at", "a la": "at", "el": "on", "los": "", "próximo": "next", "proximo": "next",
+		"mediodía": "noon", "mediodia": "noon", "medianoche": "midnight",
+		"cada": "every", "todos los": "every", "todas las": "every", "cada hora": "hourly",
+		"diario": "daily", "diariamente": "daily", "todos los días": "every day", "todos los dias": "every day",
+		"día laborable": "weekday", "dia laborable": "weekday", "días laborables": "weekdays", "dias laborables": "weekdays",
+		"fin de semana": "weekend", "y": "and",
+		"lunes": "monday", "martes": "tuesday", "miércoles": "wednesday", "miercoles": "wednesday",
+		"jueves": "thursday", "viernes": "friday", "sábado": "saturday", "sabado": "saturday",
+		"sábados": "saturday", "sabados": "saturday", "domingo": "sunday", "domingos": "sunday",
+	},
+	"fr": {
+		"dans": "in", "un": "a", "une": "a",
+		"minute": "minute", "minutes": "minutes", "heure": "hour", "heures": "hours",
+		"jour": "day", "jours": "days", "semaine": "week", "semaines": "weeks",
+		"aujourd'hui": "today", "ce soir": "tonight", "demain": "tomorrow", "après-demain": "day after tomorrow",
+		"à": "at", "a": "at", "le": "on", "prochain": "next", "prochaine": "next",
+		"midi": "noon", "minuit": "midnight",
+		"chaque": "every", "tous les": "every", "toutes les": "every", "chaque heure": "hourly", "toutes les heures": "hourly",
+		"tous les jours": "every day", "chaque jour": "every day", "quotidiennement": "daily",
+		"jour ouvré": "weekday", "jours ouvrés": "weekdays", "week-end": "weekend", "et": "and",
+		"du matin": "am", "de l'après-midi": "pm", "du soir": "pm",
+		"lundi": "monday", "mardi": "tuesday", "mercredi": "wednesday", "jeudi": "thursday",
+		"vendredi": "friday", "samedi": "saturday", "dimanche": "sunday",
+		"lundis": "monday", "mardis": "tuesday", "mercredis": "wednesday", "jeudis": "thursday",
+		"vendredis": "friday", "samedis": "saturday", "dimanches": "sunday",
+	},
+	"de": {
+		"in": "in", "einer": "a", "einem": "a", "eine": "a",
+		"minute": "minute", "minuten": "minutes", "stunde": "hour", "stunden": "hours",
+		"tag": "day", "tagen": "days", "woche": "week", "wochen": "weeks",
+		"heute": "today", "heute abend": "tonight", "morgen": "tomorrow", "übermorgen": "day after tomorrow",
+		"um": "at", "am": "on", "uhr": "", "nächsten": "next", "nächste": "next", "nächster": "next",
+		"mittag": "noon", "mitternacht": "midnight",
+		"jede": "every", "jeden": "every", "jeder": "every", "alle": "every", "stündlich": "hourly",
+		"täglich": "daily", "jeden tag": "every day", "werktags": "every weekday", "werktag": "weekday",
+		"wochenende": "weekend", "und": "and",
+		"montag": "monday", "dienstag": "tuesday", "mittwoch": "wednesday", "donnerstag": "thursday",
+		"freitag": "friday", "samstag": "saturday", "sonntag": "sunday",
+		"montags": "every monday", "dienstags": "every tuesday", "mittwochs": "every wednesday",
+		"donnerstags": "every thursday", "freitags": "every friday", "samstags": "every saturday", "sonntags": "every sunday",
+	},
+}
+
+var (
+	// French and German write "9h30" and "18 h" rather than "9:30"
+	textHourSuffix = regexp.MustCompile(`\b(\d{1,2}) ?h(?:(\d{2})\b|\b)`)
+	// French puts "prochain" after the day: "lundi prochain"
+	textTrailingNext = regexp.MustCompile(`\b(monday|tuesday|wednesday|thursday|friday|saturday|sunday) next\b`)
+)
+
+// ParseLocale validates a BCP 47 locale such as "es" or "fr-CA".
+func ParseLocale(locale string) (language.Tag, error) {
+	return language.Parse(locale)
+}
+
+// translateText rewrites normalized text in the locale's language into the
+// English phrases parseTextRules understands. Text in unsupported languages
+// is returned unchanged and left to the LLM.
+func translateText(text string, locale string) string {
+	if locale == "" {
+		return text
+	}
+	tag, err := language.Parse(locale)
+	if err != nil {
+		return text
+	}
+	base, _ := tag.Base()
+	phrases, ok := textLocalePhrases[base.String()]
+	if !ok {
+		return text
+	}
+
+	text = textHourSuffix.ReplaceAllStringFunc(text, func(match string) string {
+		parts := textHourSuffix.FindStringSubmatch(match)
+		if parts[2] == "" {
+			return parts[1]
+		}
+		return parts[1] + ":" + parts[2]
+	})
+	words := strings.Fields(strings.ReplaceAll(text, ",", " , "))
+	translated := make([]string, 0, len(words))
+	for i := 0; i < len(words); {
+		n := maxTextPhraseWords
+		for ; n > 0; n-- {
+			if i+n > len(words) {
+				continue
+			}
+			if phrase, ok := phrases[strings.Join(words[i:i+n], " ")]; ok {
+				if phrase != "" {
+					translated = append(translated, phrase)
+				}
+				break
+			}
+		}
+		if n == 0 {
+			translated = append(translated, words[i])
+			n = 1
+		}
+		i += n
+	}
+
+	text = strings.ReplaceAll(strings.Join(translated, " "), " ,", ",")
+	return textTrailingNext.ReplaceAllString(text, "next $1")
+}
@@ -0,0 +1,382 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sources a natural-language schedule can be resolved by.
+const (
+	TextSourceRules = "rules"
+	TextSourceLLM   = "llm"
+)
+
+// TextSchedule is a natural-language schedule resolved to either an RFC3339
+// time or a cron expression. A compound phrase such as "mondays at 9 plus the
+// 1st at noon" is resolved to several Triggers instead.
+type TextSchedule struct {
+	Value    string
+	IsCron   bool
+	Source   string
+	Triggers []TextSchedule `json:",omitempty"`
+}
+
+// maxTextTriggers limits how many rules a compound phrase may combine.
+const maxTextTriggers = 10
+
+const textTimePattern = `(noon|midnight|\d{1,2}(?::\d{2})?(?: ?[ap]m)?)`
+
+var (
+	textWeekdays = map[string]time.Weekday{
+		"sunday": time.Sunday, "monday": time.Monday, "tuesday": time.Tuesday, "wednesday": time.Wednesday,
+		"thursday": time.Thursday, "friday": time.Friday, "saturday": time.Saturday,
+		"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "tues": time.Tuesday, "wed": time.Wednesday,
+		"thu": time.Thursday, "thur": time.Thursday, "thurs": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+	}
+	textUnits = map[string]time.Duration{
+		"minute": time.Minute, "minutes": time.Minute, "min": time.Minute, "mins": time.Minute,
+		"hour": time.Hour, "hours": time.Hour, "hr": time.Hour, "hrs": time.Hour,
+		"day": 24 * time.Hour, "days": 24 * time.Hour,
+		"week": 7 * 24 * time.Hour, "weeks": 7 * 24 * time.Hour,
+	}
+
+	textCleanup       = regexp.MustCompile(`[.!?]+$`)
+	textSpaces        = regexp.MustCompile(`\s+`)
+	textMeridiem      = regexp.MustCompile(`\b([ap])\.m\.?`)
+	textInterval      = regexp.MustCompile(`^(?:every|each) (?:(\d+|one|two|three|four|five|six|ten|fifteen|twenty|thirty) )?(minute|minutes|min|mins|hour|hours|hr|hrs)$`)
+	textNamedInterval = regexp.MustCompile(`^(hourly|every hour|each hour)$`)
+	textRecurringDays = regexp.MustCompile(`^(?:(?:every|each) (day|weekday|weekdays|weekend|weekends|[a-z]+(?:(?:, ?| and | ?& ?)[a-z]+)*)|(daily))(?: at)? ` + textTimePattern + `$`)
+	textTimeFirst     = regexp.MustCompile(`^(?:at )?` + textTimePattern + `(?: (?:on )?(.+))?$`)
+	textDayFirst      = regexp.MustCompile(`^(.+?)(?: at)? ` + textTimePattern + `$`)
+	textDayList       = regexp.MustCompile(`, ?| and | ?& ?`)
+	textPluralDays    = regexp.MustCompile(`^([a-z]+s(?:(?:, ?| and | ?& ?)[a-z]+s)*)(?: at)? ` + textTimePattern + `$`)
+	textMonthDay      = regexp.MustCompile(`^(?:(?:every|each) month on |monthly on |on )?(?:the |every |each )?(\d{1,2})(?:st|nd|rd|th)(?: of (?:the|every|each) month)?(?: at)? ` + textTimePattern + `$`)
+	textCompound      = regexp.MustCompile(`,? plus |; |,? and also `)
+	textRelative      = regexp.MustCompile(`^in (\d+|an?|one) (minute|minutes|min|mins|hour|hours|hr|hrs|day|days|week|weeks)$`)
+
+	textNumbers = map[string]int{
+		"a": 1, "an": 1, "one": 1, "two": 2, "three": 3, "four": 4, "five": 5, "six": 6,
+		"ten": 10, "fifteen": 15, "twenty": 20, "thirty": 30,
+	}
+)
+
+// ParseTextSchedule resolves a natural-language schedule, first with the
+// built-in rules for common phrases and only then with the LLM, whose results
+// are cached in Redis. Wall-clock times are read in now's location; locale is
+// an optional BCP 47 tag naming the language of text.
+func ParseTextSchedule(ctx context.Context, text string, locale string, now time.Time) (TextSchedule, error) {
+	if schedule, ok := parseTextRules(text, locale, now); ok {
+		// The rules are deterministic, so there is nothing to repair
+		if err := validateTextResult(schedule, now); err != nil {
+			return TextSchedule{}, &TextScheduleError{Text: text, Reason: err.Error(), Result: schedule.summary(), Source: TextSourceRules}
+		}
+		return schedule, nil
+	}
+
+	if schedule, ok := cachedTextSchedule(ctx, text, locale, now); ok {
+		return schedule, nil
+	}
+	schedule, err := TextToTimeOrCronExpression(ctx, text, locale, now)
+	if err != nil {
+		return TextSchedule{}, err
+	}
+	cacheTextSchedule(ctx, text, locale, now, schedule)
+	return schedule, nil
+}
+
+// validateTextResult applies ValidateTextSchedule to a resolved schedule or
+// to each of its triggers.
+func validateTextResult(schedule TextSchedule, now time.Time) error {
+	if len(schedule.Triggers) == 0 {
+		return ValidateTextSchedule(schedule.Value, schedule.IsCron, now)
+	}
+	if len(schedule.Triggers) > maxTextTriggers {
+		return fmt.Errorf("at most %d rules can be combined", maxTextTriggers)
+	}
+	for _, trigger := range schedule.Triggers {
+		if err := ValidateTextSchedule(trigger.Value, trigger.IsCron, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// summary lists the resolved value, or the values of the triggers.
+func (s TextSchedule) summary() string {
+	if len(s.Triggers) == 0 {
+		return s.Value
+	}
+	values := make([]string, 0, len(s.Triggers))
+	for _, trigger := range s.Triggers {
+		values = append(values, trigger.Value)
+	}
+	return strings.Join(values, "; ")
+}
+
+// dependsOnNow reports whether the schedule contains a time, which phrases
+// like "in 10 minutes" resolve differently from one minute to the next.
+func (s TextSchedule) dependsOnNow() bool {
+	if len(s.Triggers) == 0 {
+		return !s.IsCron
+	}
+	for _, trigger := range s.Triggers {
+		if !trigger.IsCron {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTextRules resolves phrases such as "tomorrow at 9am", "in 15 minutes",
+// "next friday at noon", "every 10 minutes" and "every weekday at 8", and
+// compounds of them joined by "plus" or ";". Times and cron expressions are
+// expressed in now's location. Text in one of the textLocalePhrases languages
+// is translated first. It reports false for anything it does not understand.
+func parseTextRules(text string, locale string, now time.Time) (TextSchedule, bool) {
+	text = translateText(normalizeText(text), locale)
+
+	parts := textCompound.Split(text, -1)
+	if len(parts) == 1 {
+		return parseTextRule(text, now)
+	}
+	compound := TextSchedule{Source: TextSourceRules}
+	for _, part := range parts {
+		trigger, ok := parseTextRule(part, now)
+		if !ok {
+			return TextSchedule{}, false
+		}
+		compound.Triggers = append(compound.Triggers, trigger)
+	}
+	return compound, true
+}
+
+// parseTextRule resolves a single normalized phrase.
+func parseTextRule(text string, now time.Time) (TextSchedule, bool) {
+	if schedule, ok := parseTextRecurring(text); ok {
+		return schedule, true
+	}
+
+	if matches := textRelative.FindStringSubmatch(text); matches != nil {
+		count, ok := parseTextNumber(matches[1])
+		if !ok || count <= 0 {
+			return TextSchedule{}, false
+		}
+		runTime := now.Add(time.Duration(count) * textUnits[matches[2]])
+		if strings.HasPrefix(matches[2], "day") || strings.HasPrefix(matches[2], "week") {
+			// Calendar days keep the wall-clock time across DST changes
+			days := count
+			if strings.HasPrefix(matches[2], "week") {
+				days *= 7
+			}
+			runTime = now.AddDate(0, 0, days)
+		}
+		return textTime(runTime), true
+	}
+
+	// "at 5pm", "9am tomorrow", "noon on friday"
+	if matches := textTimeFirst.FindStringSubmatch(text); matches != nil {
+		if runTime, ok := resolveTextDay(matches[2], matches[1], now); ok {
+			return textTime(runTime), true
+		}
+	}
+	// "tomorrow at 9am", "next monday 10:30"
+	if matches := textDayFirst.FindStringSubmatch(text); matches != nil {
+		if runTime, ok := resolveTextDay(matches[1], matches[2], now); ok {
+			return textTime(runTime), true
+		}
+	}
+	return TextSchedule{}, false
+}
+
+func parseTextRecurring(text string) (TextSchedule, bool) {
+	if textNamedInterval.MatchString(text) {
+		return textCron("0 * * * *"), true
+	}
+
+	if matches := textInterval.FindStringSubmatch(text); matches != nil {
+		count := 1
+		if matches[1] != "" {
+			var ok bool
+			if count, ok = parseTextNumber(matches[1]); !ok || count <= 0 {
+				return TextSchedule{}, false
+			}
+		}
+		if strings.HasPrefix(matches[2], "h") {
+			switch {
+			case count == 1:
+				return textCron("0 * * * *"), true
+			case 24%count == 0:
+				return textCron(fmt.Sprintf("0 */%d * * *", count)), true
+			}
+			return textCron(fmt.Sprintf("@every %dh", count)), true
+		}
+		switch {
+		case count == 1:
+			return textCron("* * * * *"), true
+		case 60%count == 0:
+			return textCron(fmt.Sprintf("*/%d * * * *", count)), true
+		}
+		return textCron(fmt.Sprintf("@every %dm", count)), true
+	}
+
+	// "the 1st at noon" names a day of every month
+	if matches := textMonthDay.FindStringSubmatch(text); matches != nil {
+		day, err := strconv.Atoi(matches[1])
+		hour, minute, ok := parseTextClock(matches[2])
+		if err != nil || day < 1 || day > 31 || !ok {
+			return TextSchedule{}, false
+		}
+		return textCron(fmt.Sprintf("%d %d %d * *", minute, hour, day)), true
+	}
+
+	// "every monday at 9", "daily at 7", or plural days as in "mondays at 9"
+	var dayList, clock string
+	if matches := textRecurringDays.FindStringSubmatch(text); matches != nil {
+		dayList, clock = matches[1], matches[3]
+	} else if matches := textPluralDays.FindStringSubmatch(text); matches != nil {
+		dayList, clock = matches[1], matches[2]
+	} else {
+		return TextSchedule{}, false
+	}
+	hour, minute, ok := parseTextClock(clock)
+	if !ok {
+		return TextSchedule{}, false
+	}
+
+	days := "*"
+	switch dayList {
+	case "", "day":
+	case "weekday", "weekdays":
+		days = "1-5"
+	case "weekend", "weekends":
+		days = "0,6"
+	default:
+		var weekdays []string
+		for _, name := range textDayList.Split(dayList, -1) {
+			weekday, ok := textWeekdays[strings.TrimSuffix(name, "s")]
+			if !ok {
+				if weekday, ok = textWeekdays[name]; !ok {
+					return TextSchedule{}, false
+				}
+			}
+			weekdays = append(weekdays, strconv.Itoa(int(weekday)))
+		}
+		days = strings.Join(weekdays, ",")
+	}
+	return textCron(fmt.Sprintf("%d %d * * %s", minute, hour, days)), true
+}
+
+// resolveTextDay combines a day phrase with a clock time. Without a day the
+// next occurrence of the time is used. A time already passed today is not
+// resolved, leaving the phrase to the LLM.
+func resolveTextDay(day string, clock string, now time.Time) (time.Time, bool) {
+	hour, minute, ok := parseTextClock(clock)
+	if !ok {
+		return time.Time{}, false
+	}
+	at := func(date time.Time) time.Time {
+		return time.Date(date.Year(), date.Month(), date.Day(), hour, minute, 0, 0, now.Location())
+	}
+
+	day = strings.TrimPrefix(day, "on ")
+	switch day {
+	case "":
+		runTime := at(now)
+		if !runTime.After(now) {
+			runTime = at(now.AddDate(0, 0, 1))
+		}
+		return runTime, true
+	case "today", "tonight":
+		runTime := at(now)
+		return runTime, runTime.After(now)
+	case "tomorrow":
+		return at(now.AddDate(0, 0, 1)), true
+	case "day after tomorrow", "the day after tomorrow":
+		return at(now.AddDate(0, 0, 2)), true
+	}
+
+	next := strings.HasPrefix(day, "next ")
+	day = strings.TrimPrefix(strings.TrimPrefix(day, "next "), "this ")
+	weekday, ok := textWeekdays[day]
+	if !ok {
+		return time.Time{}, false
+	}
+	offset := (int(weekday) - int(now.Weekday()) + 7) % 7
+	runTime := at(now.AddDate(0, 0, offset))
+	// "next monday" on a monday, or a time already passed today, means next week
+	if (offset == 0 && next) || !runTime.After(now) {
+		runTime = at(now.AddDate(0, 0, offset+7))
+	}
+	return runTime, true
+}
+
+// parseTextClock reads "9", "9am", "9:30 pm", "21:15", "noon" and "midnight".
+// A bare hour is read on the 24-hour clock.
+func parseTextClock(clock string) (int, int, bool) {
+	switch clock {
+	case "noon":
+		return 12, 0, true
+	case "midnight":
+		return 0, 0, true
+	}
+
+	meridiem := ""
+	if strings.HasSuffix(clock, "am") || strings.HasSuffix(clock, "pm") {
+		meridiem = clock[len(clock)-2:]
+		clock = strings.TrimSpace(clock[:len(clock)-2])
+	}
+	hourText, minuteText, _ := strings.Cut(clock, ":")
+	hour, err := strconv.Atoi(hourText)
+	if err != nil {
+		return 0, 0, false
+	}
+	minute := 0
+	if minuteText != "" {
+		if minute, err = strconv.Atoi(minuteText); err != nil || minute > 59 {
+			return 0, 0, false
+		}
+	}
+
+	switch meridiem {
+	case "am", "pm":
+		if hour < 1 || hour > 12 {
+			return 0, 0, false
+		}
+		hour %= 12
+		if meridiem == "pm" {
+			hour += 12
+		}
+	default:
+		if hour > 23 {
+			return 0, 0, false
+		}
+	}
+	return hour, minute, true
+}
+
+func parseTextNumber(value string) (int, bool) {
+	if number, ok := textNumbers[value]; ok {
+		return number, true
+	}
+	number, err := strconv.Atoi(value)
+	return number, err == nil
+}
+
+func normalizeText(text string) string {
+	text = strings.ToLower(strings.TrimSpace(text))
+	text = textMeridiem.ReplaceAllString(text, "${1}m")
+	text = textCleanup.ReplaceAllString(text, "")
+	return textSpaces.ReplaceAllString(text, " ")
+}
+
+func textTime(runTime time.Time) TextSchedule {
+	return TextSchedule{Value: runTime.Format(time.RFC3339), Source: TextSourceRules}
+}
+
+func textCron(expression string) TextSchedule {
+	return TextSchedule{Value: expression, IsCron: true, Source: TextSourceRules}
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTextRules(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A Wednesday morning
+	now := time.Date(2025, 6, 11, 10, 0, 0, 0, loc)
+
+	tests := []struct {
+		text   string
+		locale string
+		want   string
+		ok     bool
+	}{
+		{"in 15 minutes", "", "2025-06-11T10:15:00-04:00", true},
+		{"in an hour", "", "2025-06-11T11:00:00-04:00", true},
+		{"in 2 days", "", "2025-06-13T10:00:00-04:00", true},
+		{"tomorrow at 9am", "", "2025-06-12T09:00:00-04:00", true},
+		{"today at 5pm", "", "2025-06-11T17:00:00-04:00", true},
+		{"tonight at 9:30 p.m.", "", "2025-06-11T21:30:00-04:00", true},
+		{"5pm", "", "2025-06-11T17:00:00-04:00", true},
+		{"at 8", "", "2025-06-12T08:00:00-04:00", true},
+		{"wednesday at 11am", "", "2025-06-11T11:00:00-04:00", true},
+		{"wednesday at 9am", "", "2025-06-18T09:00:00-04:00", true},
+		{"next friday at noon", "", "2025-06-13T12:00:00-04:00", true},
+		{"next wednesday at 11am", "", "2025-06-18T11:00:00-04:00", true},
+		{"every 10 minutes", "", "*/10 * * * *", true},
+		{"every 7 minutes", "", "@every 7m", true},
+		{"hourly", "", "0 * * * *", true},
+		{"every 6 hours", "", "0 */6 * * *", true},
+		{"daily at 7:30", "", "30 7 * * *", true},
+		{"every weekday at 8", "", "0 8 * * 1-5", true},
+		{"every monday and wednesday at 18:00", "", "0 18 * * 1,3", true},
+		{"the 1st at noon", "", "0 12 1 * *", true},
+		{"mondays and thursdays at 9, plus the 1st at noon", "", "0 9 * * 1,4; 0 12 1 * *", true},
+		{"mañana a las 9", "es", "2025-06-12T09:00:00-04:00", true},
+
+		// A time already passed today is left to the LLM
+		{"today at 9am", "", "", false},
+		{"tonight at 8am", "", "", false},
+		{"at 25", "", "", false},
+		{"every 0 minutes", "", "", false},
+		{"the 32nd at noon", "", "", false},
+		{"sometime after lunch", "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			got, ok := parseTextRules(tt.text, tt.locale, now)
+			if ok != tt.ok {
+				t.Fatalf("parseTextRules(%q) ok = %v, want %v (got %q)", tt.text, ok, tt.ok, got.summary())
+			}
+			if ok && got.summary() != tt.want {
+				t.Errorf("parseTextRules(%q) = %q, want %q", tt.text, got.summary(), tt.want)
+			}
+		})
+	}
+}
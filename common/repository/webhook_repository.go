@@ -2,39 +2,159 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/Sumit189/letItGo/common/database"
+	"github.com/Sumit189/letItGo/common/models"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// WebhookRevokedReason is the status_reason of schedules blocked because the
+// verification of their webhook was revoked.
+const WebhookRevokedReason = "webhook verification revoked"
+
+var ErrWebhookNotFound = errors.New("verified webhook not found")
+
 var VerifiedWebhooks *mongo.Collection
 
 func InitializeVerifiedWebhooksRepository() {
 	VerifiedWebhooks = database.GetCollection("verifiedwebhooks")
 }
 
+// IsVerifiedWebhook reports whether the webhook has a verification that is
+// neither revoked nor expired, or lies on a verified domain. Revoking the
+// webhook itself takes precedence over a domain verification.
 func IsVerifiedWebhook(ctx context.Context, webhookURL string, methodType string) bool {
 	if os.Getenv("ENVIRONMENT") == "development" {
 		return true
 	}
-	var result bson.M
-	err := VerifiedWebhooks.FindOne(ctx, bson.M{"webhook_url": webhookURL, "method_type": methodType, "verified": true}).Decode(&result)
-	if err != nil {
-		if err == mongo.ErrNoDocuments {
+	var webhook models.VerifiedWebhooks
+	err := VerifiedWebhooks.FindOne(ctx, bson.M{"webhook_url": webhookURL, "method_type": methodType}).Decode(&webhook)
+	if err == nil {
+		if webhook.RevokedAt != nil {
 			return false
 		}
-		return false
+		if webhook.IsActive(time.Now()) {
+			return true
+		}
 	}
-
-	return true
+	return isVerifiedDomainURL(ctx, webhookURL)
 }
 
+// AddVerifiedWebhook records a successful verification. Verifying a webhook
+// again renews its expiry and lifts a revocation, returning its blocked
+// schedules to the status they had before.
 func AddVerifiedWebhook(ctx context.Context, webhookURL string, methodType string) error {
-	_, err := VerifiedWebhooks.InsertOne(ctx, bson.M{"webhook_url": webhookURL, "method_type": methodType, "verified": true})
+	now := time.Now()
+	set := bson.M{"verified": true, "verified_at": now, "updated_at": now}
+	unset := bson.M{"revoked_at": ""}
+	if ttl := webhookVerificationTTL(); ttl > 0 {
+		set["expires_at"] = now.Add(ttl)
+	} else {
+		unset["expires_at"] = ""
+	}
+
+	_, err := VerifiedWebhooks.UpdateOne(
+		ctx,
+		bson.M{"webhook_url": webhookURL, "method_type": methodType},
+		bson.M{"$set": set, "$unset": unset, "$setOnInsert": bson.M{"created_at": now}},
+		options.Update().SetUpsert(true),
+	)
 	if err != nil {
 		return err
 	}
-	return nil
+
+	// Paused schedules stay paused; a schedule blocked while being dispatched
+	// was dropped by the consumer and goes back to pending
+	_, err = SchedulerCollection.UpdateMany(
+		ctx,
+		bson.M{"webhook_url": webhookURL, "method_type": methodType, "status": "blocked"},
+		mongo.Pipeline{
+			{{Key: "$set", Value: bson.M{
+				"status":        bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$blocked_from", "paused"}}, "paused", "pending"}},
+				"status_reason": "",
+				"updated_at":    now,
+			}}},
+			{{Key: "$unset", Value: "blocked_from"}},
+		},
+	)
+	return err
+}
+
+// ListVerifiedWebhooks returns all verification records, including revoked
+// and expired ones, newest first.
+func ListVerifiedWebhooks(ctx context.Context) ([]models.VerifiedWebhooks, error) {
+	cursor, err := VerifiedWebhooks.Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"updated_at": -1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	webhooks := []models.VerifiedWebhooks{}
+	if err := cursor.All(ctx, &webhooks); err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+// RevokeVerifiedWebhook revokes a verification. Schedules of the webhook that
+// have not started executing are blocked until it is verified again; the
+// number of blocked schedules is returned.
+func RevokeVerifiedWebhook(ctx context.Context, id string) (models.VerifiedWebhooks, int64, error) {
+	webhookID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return models.VerifiedWebhooks{}, 0, ErrWebhookNotFound
+	}
+
+	now := time.Now()
+	var webhook models.VerifiedWebhooks
+	err = VerifiedWebhooks.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": webhookID},
+		bson.M{"$set": bson.M{"verified": false, "revoked_at": now, "updated_at": now}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&webhook)
+	if err == mongo.ErrNoDocuments {
+		return models.VerifiedWebhooks{}, 0, ErrWebhookNotFound
+	}
+	if err != nil {
+		return models.VerifiedWebhooks{}, 0, err
+	}
+
+	result, err := SchedulerCollection.UpdateMany(
+		ctx,
+		bson.M{
+			"webhook_url": webhook.WebhookURL,
+			"method_type": webhook.MethodType,
+			"status":      bson.M{"$in": []string{"pending", "processing", "paused"}},
+		},
+		mongo.Pipeline{{{Key: "$set", Value: bson.M{
+			"blocked_from":  "$status",
+			"status":        "blocked",
+			"status_reason": WebhookRevokedReason,
+			"updated_at":    now,
+		}}}},
+	)
+	if err != nil {
+		return webhook, 0, fmt.Errorf("failed to block schedules of revoked webhook: %w", err)
+	}
+	return webhook, result.ModifiedCount, nil
+}
+
+// webhookVerificationTTL returns how long a verification stays valid, from
+// WEBHOOK_VERIFICATION_TTL_HOURS. Zero, the default, means forever.
+func webhookVerificationTTL() time.Duration {
+	if value := os.Getenv("WEBHOOK_VERIFICATION_TTL_HOURS"); value != "" {
+		if hours, err := strconv.Atoi(value); err == nil && hours > 0 {
+			return time.Duration(hours) * time.Hour
+		}
+	}
+	return 0
 }
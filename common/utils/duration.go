@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"errors"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var isoDurationPattern = regexp.MustCompile(`^P(?:(\d+)Y)?(?:(\d+)M)?(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+(?:[.,]\d+)?)S)?)?$`)
+
+// AddISODuration adds an ISO 8601 duration such as PT15M or P1DT2H to t.
+// Years, months, weeks and days are calendar units applied in t's location;
+// hours, minutes and seconds are exact.
+func AddISODuration(t time.Time, value string) (time.Time, error) {
+	matches := isoDurationPattern.FindStringSubmatch(value)
+	if matches == nil || value == "P" || value[len(value)-1] == 'T' {
+		return time.Time{}, errors.New("invalid ISO 8601 duration: " + value)
+	}
+
+	var parts [6]int
+	for i := range parts {
+		if matches[i+1] == "" {
+			continue
+		}
+		n, err := strconv.Atoi(matches[i+1])
+		if err != nil {
+			return time.Time{}, errors.New("invalid ISO 8601 duration: " + value)
+		}
+		parts[i] = n
+	}
+	seconds := 0.0
+	if matches[7] != "" {
+		// Both "." and "," are valid decimal signs in ISO 8601
+		parsed, err := strconv.ParseFloat(strings.Replace(matches[7], ",", ".", 1), 64)
+		if err != nil {
+			return time.Time{}, errors.New("invalid ISO 8601 duration: " + value)
+		}
+		seconds = parsed
+	}
+
+	years, months, weeks, days, hours, minutes := parts[0], parts[1], parts[2], parts[3], parts[4], parts[5]
+	result := t.AddDate(years, months, weeks*7+days)
+	exact := float64(hours)*3600 + float64(minutes)*60 + seconds
+	if exact*float64(time.Second) > math.MaxInt64 {
+		return time.Time{}, errors.New("ISO 8601 duration is too long: " + value)
+	}
+	return result.Add(time.Duration(exact * float64(time.Second))), nil
+}
@@ -0,0 +1,306 @@
+package utils
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// legacyKeyID names PAYLOAD_ENCRYPTION_KEY in the keyring.
+const legacyKeyID = "default"
+
+var keyIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+var (
+	ErrUnknownKeyID = errors.New("ciphertext was encrypted with a key that is not in the keyring")
+	ErrNoKeys       = errors.New("no key set, configure PAYLOAD_ENCRYPTION_KEYS or PAYLOAD_ENCRYPTION_KEY")
+)
+
+// Keyring holds the AES keys payloads can be decrypted with. New payloads are
+// encrypted with AES-GCM under the current key and prefixed with its ID,
+// "<key id>:<base64 nonce+ciphertext>". Payloads without a prefix predate the
+// keyring and were encrypted with AES-CFB under PAYLOAD_ENCRYPTION_KEY.
+type Keyring struct {
+	current string
+	keys    map[string]cipher.AEAD
+	legacy  cipher.Block
+}
+
+var keyring *Keyring
+
+// AESInit loads the keyring and, with KMS_PROVIDER set, the key provider
+// from the environment, and panics if either is invalid:
+//
+//	PAYLOAD_ENCRYPTION_KEYS    comma-separated <id>:<key> pairs
+//	PAYLOAD_ENCRYPTION_KEY_ID  ID of the key new payloads are encrypted with,
+//	                           by default the last one listed
+//	PAYLOAD_ENCRYPTION_KEY     single key, known as "default"; also decrypts
+//	                           payloads stored before the keyring existed
+//
+// With a key provider, new payloads use envelope encryption and the keyring,
+// which may then be empty, only decrypts payloads stored before.
+func AESInit() {
+	provider, err := NewKeyProvider()
+	if err != nil {
+		panic("Failed to initialise key provider: " + err.Error())
+	}
+	ring, err := loadEnvKeyring()
+	if errors.Is(err, ErrNoKeys) && provider != nil {
+		ring, err = &Keyring{keys: map[string]cipher.AEAD{}}, nil
+	}
+	if err != nil {
+		panic("Failed to load encryption keyring: " + err.Error())
+	}
+	keyring = ring
+	if provider != nil {
+		envelope = newEnvelopeCipher(provider, dataKeyTTL())
+	}
+}
+
+func loadEnvKeyring() (*Keyring, error) {
+	return LoadKeyring(os.Getenv("PAYLOAD_ENCRYPTION_KEYS"), os.Getenv("PAYLOAD_ENCRYPTION_KEY_ID"), os.Getenv("PAYLOAD_ENCRYPTION_KEY"))
+}
+
+// LoadKeyring builds a keyring from the values of the PAYLOAD_ENCRYPTION_*
+// variables. Keys are 16, 24 or 32 bytes, raw or prefixed with "base64:".
+func LoadKeyring(keys string, currentID string, legacyKey string) (*Keyring, error) {
+	ring := &Keyring{keys: map[string]cipher.AEAD{}}
+
+	if legacyKey != "" {
+		block, err := newKeyBlock(legacyKey)
+		if err != nil {
+			return nil, fmt.Errorf("PAYLOAD_ENCRYPTION_KEY: %w", err)
+		}
+		ring.legacy = block
+		if ring.keys[legacyKeyID], err = cipher.NewGCM(block); err != nil {
+			return nil, err
+		}
+		ring.current = legacyKeyID
+	}
+
+	for _, entry := range strings.Split(keys, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, key, ok := strings.Cut(entry, ":")
+		if !ok || !keyIDPattern.MatchString(id) {
+			return nil, fmt.Errorf("PAYLOAD_ENCRYPTION_KEYS: entries must be <id>:<key> with IDs of letters, digits, '-' and '_'")
+		}
+		if id+":" == envelopePrefix {
+			return nil, fmt.Errorf("PAYLOAD_ENCRYPTION_KEYS: key ID %q is reserved", id)
+		}
+		if _, exists := ring.keys[id]; exists {
+			return nil, fmt.Errorf("PAYLOAD_ENCRYPTION_KEYS: duplicate key ID %q", id)
+		}
+		block, err := newKeyBlock(key)
+		if err != nil {
+			return nil, fmt.Errorf("PAYLOAD_ENCRYPTION_KEYS: key %q: %w", id, err)
+		}
+		if ring.keys[id], err = cipher.NewGCM(block); err != nil {
+			return nil, err
+		}
+		ring.current = id
+	}
+
+	if currentID != "" {
+		if _, ok := ring.keys[currentID]; !ok {
+			return nil, fmt.Errorf("PAYLOAD_ENCRYPTION_KEY_ID %q is not in the keyring", currentID)
+		}
+		ring.current = currentID
+	}
+	if ring.current == "" {
+		return nil, ErrNoKeys
+	}
+	return ring, nil
+}
+
+func newKeyBlock(key string) (cipher.Block, error) {
+	raw := []byte(key)
+	if encoded, ok := strings.CutPrefix(key, "base64:"); ok {
+		var err error
+		if raw, err = base64.StdEncoding.DecodeString(encoded); err != nil {
+			return nil, fmt.Errorf("invalid base64 key: %w", err)
+		}
+	}
+	return aes.NewCipher(raw)
+}
+
+// CurrentKeyID returns the ID of the key new payloads are encrypted with.
+func (k *Keyring) CurrentKeyID() string {
+	return k.current
+}
+
+// Seal encrypts plaintext under the current key.
+func (k *Keyring) Seal(plaintext []byte) (string, error) {
+	aead := k.keys[k.current]
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	// The key ID is authenticated so a prefix cannot be swapped
+	sealed := aead.Seal(nonce, nonce, plaintext, []byte(k.current))
+	return k.current + ":" + base64.URLEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a ciphertext produced by Seal or, without a key ID prefix, by
+// the AES-CFB encryption used before the keyring.
+func (k *Keyring) Open(ciphertext string) ([]byte, error) {
+	id, encoded, ok := strings.Cut(ciphertext, ":")
+	if !ok {
+		return k.openLegacy(ciphertext)
+	}
+	aead, found := k.keys[id]
+	if !found {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKeyID, id)
+	}
+	sealed, err := base64.URLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(id))
+	if err != nil {
+		return nil, errors.New("ciphertext failed authentication")
+	}
+	return plaintext, nil
+}
+
+func (k *Keyring) openLegacy(ciphertext string) ([]byte, error) {
+	if k.legacy == nil {
+		return nil, errors.New("ciphertext predates the keyring, set PAYLOAD_ENCRYPTION_KEY to decrypt it")
+	}
+	data, err := base64.URLEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < aes.BlockSize {
+		return nil, errors.New("ciphertext too short")
+	}
+	iv := data[:aes.BlockSize]
+	plaintext := make([]byte, len(data)-aes.BlockSize)
+	cipher.NewCFBDecrypter(k.legacy, iv).XORKeyStream(plaintext, data[aes.BlockSize:])
+	return plaintext, nil
+}
+
+// KeyID returns the ID of the key a ciphertext was encrypted with, "envelope"
+// for envelope encryption, or "" for ciphertexts that predate the keyring.
+func KeyID(ciphertext string) string {
+	id, _, ok := strings.Cut(ciphertext, ":")
+	if !ok {
+		return ""
+	}
+	return id
+}
+
+// seal encrypts plaintext under the current scheme: envelope encryption with a
+// key provider, otherwise the current key of the keyring.
+func seal(plaintext []byte) (string, error) {
+	if envelope != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), kmsTimeout)
+		defer cancel()
+		return envelope.Seal(ctx, plaintext)
+	}
+	return keyring.Seal(plaintext)
+}
+
+func open(ciphertext string) ([]byte, error) {
+	if strings.HasPrefix(ciphertext, envelopePrefix) {
+		if envelope == nil {
+			return nil, errors.New("payload uses envelope encryption, set KMS_PROVIDER to decrypt it")
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), kmsTimeout)
+		defer cancel()
+		return envelope.Open(ctx, ciphertext)
+	}
+	return keyring.Open(ciphertext)
+}
+
+// NeedsReencryption reports whether a ciphertext was produced by an earlier
+// configuration: AES-CFB, a key other than the current one or, with a key
+// provider, without envelope encryption or with a data key wrapped by a
+// master key other than the provider's current one.
+func NeedsReencryption(ciphertext string) (bool, error) {
+	if envelope != nil {
+		if !strings.HasPrefix(ciphertext, envelopePrefix) {
+			return true, nil
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), kmsTimeout)
+		defer cancel()
+		current, err := envelope.currentMasterKeyID(ctx)
+		if err != nil {
+			return false, err
+		}
+		return envelope.masterKeyID(ciphertext) != current, nil
+	}
+	return KeyID(ciphertext) != keyring.CurrentKeyID(), nil
+}
+
+// Reencrypt decrypts a ciphertext with whichever key it was encrypted with
+// and encrypts the same plaintext under the current configuration. Payloads
+// are JSON; anything else means a wrong key, which AES-CFB cannot detect, and
+// is refused rather than sealed under an authenticated key.
+func Reencrypt(ciphertext string) (string, error) {
+	plaintext, err := open(ciphertext)
+	if err != nil {
+		return "", err
+	}
+	if !json.Valid(plaintext) {
+		return "", errors.New("decrypted payload is not valid JSON, the key is probably wrong")
+	}
+	return seal(plaintext)
+}
+
+func Encrypt(data interface{}) (string, error) {
+	plaintext, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+	return seal(plaintext)
+}
+
+func Decrypt(encryptedData string) (interface{}, error) {
+	plaintext, err := open(encryptedData)
+	if err != nil {
+		return nil, err
+	}
+
+	var result interface{}
+	err = json.Unmarshal(plaintext, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func DecryptAndConvertToJSON(encryptedData string) (interface{}, error) {
+	decryptedData, err := Decrypt(encryptedData)
+	if err != nil {
+		return nil, err
+	}
+
+	// Ensure decryptedPayload is a string
+	decryptedPayloadStr, ok := decryptedData.(string)
+	if !ok {
+		return nil, errors.New("decrypted payload is not a string")
+	}
+
+	// Validate the decrypted payload as JSON without unmarshaling into a map
+	if !json.Valid([]byte(decryptedPayloadStr)) {
+		return nil, errors.New("decrypted payload is not valid JSON")
+	}
+
+	return []byte(decryptedPayloadStr), nil
+}
@@ -0,0 +1,138 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+)
+
+const (
+	testKeyA = "abcdefghijklmnopabcdefghijklmnop"
+	testKeyB = "ABCDEFGHIJKLMNOPABCDEFGHIJKLMNOP"
+)
+
+// useKeyring installs ring, and optionally envelope encryption, for the
+// duration of a test.
+func useKeyring(t *testing.T, ring *Keyring, provider KeyProvider) {
+	t.Helper()
+	previousRing, previousEnvelope := keyring, envelope
+	keyring, envelope = ring, nil
+	if provider != nil {
+		envelope = newEnvelopeCipher(provider, defaultDataKeyTTL)
+	}
+	t.Cleanup(func() { keyring, envelope = previousRing, previousEnvelope })
+}
+
+func mustKeyring(t *testing.T, keys string, currentID string, legacyKey string) *Keyring {
+	t.Helper()
+	ring, err := LoadKeyring(keys, currentID, legacyKey)
+	if err != nil {
+		t.Fatalf("LoadKeyring(%q, %q): %v", keys, currentID, err)
+	}
+	return ring
+}
+
+// legacyEncrypt encrypts data the way payloads were stored before the
+// keyring: AES-CFB without authentication or key ID.
+func legacyEncrypt(t *testing.T, key string, data interface{}) string {
+	t.Helper()
+	plaintext, err := json.Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := aes.NewCipher([]byte(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext := make([]byte, aes.BlockSize+len(plaintext))
+	copy(ciphertext[:aes.BlockSize], "0123456789abcdef")
+	cipher.NewCFBEncrypter(block, ciphertext[:aes.BlockSize]).XORKeyStream(ciphertext[aes.BlockSize:], plaintext)
+	return base64.URLEncoding.EncodeToString(ciphertext)
+}
+
+func TestReencryptRefusesWrongLegacyKey(t *testing.T) {
+	stored := legacyEncrypt(t, testKeyA, `{"a":1}`)
+	useKeyring(t, mustKeyring(t, "k1:"+testKeyB, "", testKeyB), nil)
+
+	if _, err := Reencrypt(stored); err == nil {
+		t.Fatal("Reencrypt sealed a payload decrypted with the wrong legacy key")
+	}
+}
+
+func TestReencryptMovesToCurrentKey(t *testing.T) {
+	useKeyring(t, mustKeyring(t, "k1:"+testKeyA, "", ""), nil)
+	stored, err := Encrypt(`{"a":1}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	useKeyring(t, mustKeyring(t, "k1:"+testKeyA+",k2:"+testKeyB, "", ""), nil)
+	if stale, _ := NeedsReencryption(stored); !stale {
+		t.Fatal("payload under k1 is not reported stale after rotating to k2")
+	}
+	reencrypted, err := Reencrypt(stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if KeyID(reencrypted) != "k2" {
+		t.Errorf("re-encrypted under %q, want k2", KeyID(reencrypted))
+	}
+	if stale, _ := NeedsReencryption(reencrypted); stale {
+		t.Error("re-encrypted payload is still reported stale")
+	}
+}
+
+func TestNeedsReencryptionAfterMasterKeyRotation(t *testing.T) {
+	useKeyring(t, mustKeyring(t, "k1:"+testKeyA, "", ""), &LocalKeyProvider{ring: mustKeyring(t, "m1:"+testKeyA, "", "")})
+	stored, err := Encrypt(`{"a":1}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stale, err := NeedsReencryption(stored); err != nil || stale {
+		t.Fatalf("NeedsReencryption = %v, %v before rotation, want false", stale, err)
+	}
+
+	useKeyring(t, keyring, &LocalKeyProvider{ring: mustKeyring(t, "m1:"+testKeyA+",m2:"+testKeyB, "", "")})
+	if stale, err := NeedsReencryption(stored); err != nil || !stale {
+		t.Fatalf("NeedsReencryption = %v, %v after rotating the master key, want true", stale, err)
+	}
+	reencrypted, err := Reencrypt(stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stale, _ := NeedsReencryption(reencrypted); stale {
+		t.Error("payload is still stale after re-encryption")
+	}
+}
+
+func TestKeyringOpensLegacyCiphertext(t *testing.T) {
+	stored := legacyEncrypt(t, testKeyA, map[string]int{"a": 1})
+	if KeyID(stored) != "" {
+		t.Fatalf("KeyID(%q) = %q, want none for a legacy ciphertext", stored, KeyID(stored))
+	}
+
+	ring := mustKeyring(t, "k1:"+testKeyB, "", testKeyA)
+	plaintext, err := ring.Open(stored)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if string(plaintext) != `{"a":1}` {
+		t.Errorf("Open = %q, want {\"a\":1}", plaintext)
+	}
+
+	// New payloads are sealed with the current key, not the legacy one
+	sealed, err := ring.Seal(plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if KeyID(sealed) != "k1" {
+		t.Errorf("sealed under %q, want k1", KeyID(sealed))
+	}
+
+	withoutLegacy := mustKeyring(t, "k1:"+testKeyB, "", "")
+	if _, err := withoutLegacy.Open(stored); err == nil {
+		t.Error("Open decrypted a legacy ciphertext without PAYLOAD_ENCRYPTION_KEY")
+	}
+}
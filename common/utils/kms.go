@@ -0,0 +1,204 @@
+package utils
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	envelopePrefix    = "envelope:"
+	dataKeySize       = 32
+	defaultDataKeyTTL = time.Hour
+	kmsTimeout        = 10 * time.Second
+	maxOpenedDataKeys = 1024
+)
+
+// KeyProvider wraps and unwraps data keys with a master key it manages, in
+// the style of a KMS. The master key never leaves the provider.
+type KeyProvider interface {
+	Name() string
+	WrapKey(ctx context.Context, dataKey []byte) (string, error)
+	UnwrapKey(ctx context.Context, wrapped string) ([]byte, error)
+	// KeyID returns the ID or version of the master key that wrapped a
+	// data key.
+	KeyID(wrapped string) string
+}
+
+// envelopeCipher encrypts payloads with AES-GCM data keys that are wrapped
+// by the provider and stored with each ciphertext,
+// "envelope:<base64 wrapped key>.<base64 nonce+ciphertext>". A data key is
+// reused for KMS_DATA_KEY_TTL_SECONDS so that not every payload costs a call
+// to the provider, and unwrapped keys are cached for decryption.
+type envelopeCipher struct {
+	provider KeyProvider
+	ttl      time.Duration
+
+	mu      sync.Mutex
+	wrapped string
+	aead    cipher.AEAD
+	expires time.Time
+	opened  map[string]cipher.AEAD
+}
+
+var envelope *envelopeCipher
+
+func newEnvelopeCipher(provider KeyProvider, ttl time.Duration) *envelopeCipher {
+	return &envelopeCipher{provider: provider, ttl: ttl, opened: map[string]cipher.AEAD{}}
+}
+
+// NewKeyProvider returns the provider named by KMS_PROVIDER, or nil when it
+// is unset and payloads are encrypted directly with the keyring.
+func NewKeyProvider() (KeyProvider, error) {
+	switch name := os.Getenv("KMS_PROVIDER"); name {
+	case "":
+		return nil, nil
+	case "local":
+		return NewLocalKeyProvider(os.Getenv("KMS_LOCAL_KEY_FILE"), os.Getenv("KMS_LOCAL_KEY_ID"))
+	case "vault":
+		return NewVaultKeyProvider(VaultConfigFromEnv())
+	default:
+		return nil, fmt.Errorf("unknown KMS_PROVIDER %q, expected local or vault", name)
+	}
+}
+
+func dataKeyTTL() time.Duration {
+	if value := os.Getenv("KMS_DATA_KEY_TTL_SECONDS"); value != "" {
+		if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+	return defaultDataKeyTTL
+}
+
+// dataKey returns the data key to encrypt with, generating and wrapping a new
+// one when the current one has expired.
+func (e *envelopeCipher) dataKey(ctx context.Context) (string, cipher.AEAD, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.aead != nil && time.Now().Before(e.expires) {
+		return e.wrapped, e.aead, nil
+	}
+
+	key := make([]byte, dataKeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return "", nil, err
+	}
+	wrapped, err := e.provider.WrapKey(ctx, key)
+	if err != nil {
+		return "", nil, fmt.Errorf("%s: failed to wrap data key: %w", e.provider.Name(), err)
+	}
+	aead, err := newDataKeyAEAD(key)
+	if err != nil {
+		return "", nil, err
+	}
+	e.wrapped, e.aead, e.expires = base64.URLEncoding.EncodeToString([]byte(wrapped)), aead, time.Now().Add(e.ttl)
+	return e.wrapped, e.aead, nil
+}
+
+// openDataKey unwraps the data key of a ciphertext, from the cache if it was
+// seen before.
+func (e *envelopeCipher) openDataKey(ctx context.Context, encodedWrapped string) (cipher.AEAD, error) {
+	e.mu.Lock()
+	aead, ok := e.opened[encodedWrapped]
+	e.mu.Unlock()
+	if ok {
+		return aead, nil
+	}
+
+	wrapped, err := base64.URLEncoding.DecodeString(encodedWrapped)
+	if err != nil {
+		return nil, err
+	}
+	key, err := e.provider.UnwrapKey(ctx, string(wrapped))
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to unwrap data key: %w", e.provider.Name(), err)
+	}
+	if aead, err = newDataKeyAEAD(key); err != nil {
+		return nil, err
+	}
+
+	e.mu.Lock()
+	if len(e.opened) >= maxOpenedDataKeys {
+		e.opened = map[string]cipher.AEAD{}
+	}
+	e.opened[encodedWrapped] = aead
+	e.mu.Unlock()
+	return aead, nil
+}
+
+func newDataKeyAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// masterKeyID returns the ID of the master key that wrapped the data key of
+// an envelope ciphertext.
+func (e *envelopeCipher) masterKeyID(ciphertext string) string {
+	header, _, _ := strings.Cut(ciphertext, ".")
+	wrapped, err := base64.URLEncoding.DecodeString(strings.TrimPrefix(header, envelopePrefix))
+	if err != nil {
+		return ""
+	}
+	return e.provider.KeyID(string(wrapped))
+}
+
+// currentMasterKeyID returns the ID of the master key new data keys are
+// wrapped with, as reported for the current data key.
+func (e *envelopeCipher) currentMasterKeyID(ctx context.Context) (string, error) {
+	wrapped, _, err := e.dataKey(ctx)
+	if err != nil {
+		return "", err
+	}
+	return e.masterKeyID(envelopePrefix + wrapped), nil
+}
+
+func (e *envelopeCipher) Seal(ctx context.Context, plaintext []byte) (string, error) {
+	wrapped, aead, err := e.dataKey(ctx)
+	if err != nil {
+		return "", err
+	}
+	header := envelopePrefix + wrapped
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, plaintext, []byte(header))
+	return header + "." + base64.URLEncoding.EncodeToString(sealed), nil
+}
+
+func (e *envelopeCipher) Open(ctx context.Context, ciphertext string) ([]byte, error) {
+	header, encoded, ok := strings.Cut(ciphertext, ".")
+	if !ok {
+		return nil, errors.New("malformed envelope ciphertext")
+	}
+	aead, err := e.openDataKey(ctx, strings.TrimPrefix(header, envelopePrefix))
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := base64.URLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(header))
+	if err != nil {
+		return nil, errors.New("ciphertext failed authentication")
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,132 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// VaultConfig locates a HashiCorp Vault transit key.
+type VaultConfig struct {
+	Address   string // VAULT_ADDR
+	Token     string // VAULT_TOKEN
+	Namespace string // VAULT_NAMESPACE, Vault Enterprise only
+	Mount     string // VAULT_TRANSIT_MOUNT, "transit" by default
+	Key       string // VAULT_TRANSIT_KEY, "letitgo" by default
+}
+
+func VaultConfigFromEnv() VaultConfig {
+	config := VaultConfig{
+		Address:   os.Getenv("VAULT_ADDR"),
+		Token:     os.Getenv("VAULT_TOKEN"),
+		Namespace: os.Getenv("VAULT_NAMESPACE"),
+		Mount:     os.Getenv("VAULT_TRANSIT_MOUNT"),
+		Key:       os.Getenv("VAULT_TRANSIT_KEY"),
+	}
+	if config.Mount == "" {
+		config.Mount = "transit"
+	}
+	if config.Key == "" {
+		config.Key = "letitgo"
+	}
+	return config
+}
+
+// VaultKeyProvider wraps data keys with a Vault transit key. Vault keeps the
+// master key and its versions; rotating it in Vault does not affect data keys
+// wrapped earlier.
+type VaultKeyProvider struct {
+	config VaultConfig
+	client *http.Client
+}
+
+func NewVaultKeyProvider(config VaultConfig) (*VaultKeyProvider, error) {
+	if config.Address == "" || config.Token == "" {
+		return nil, errors.New("the vault provider requires VAULT_ADDR and VAULT_TOKEN")
+	}
+	config.Address = strings.TrimSuffix(config.Address, "/")
+	return &VaultKeyProvider{config: config, client: &http.Client{Timeout: kmsTimeout}}, nil
+}
+
+func (p *VaultKeyProvider) Name() string {
+	return "vault"
+}
+
+func (p *VaultKeyProvider) WrapKey(ctx context.Context, dataKey []byte) (string, error) {
+	var response struct {
+		Ciphertext string `json:"ciphertext"`
+	}
+	err := p.transit(ctx, "encrypt", map[string]string{"plaintext": base64.StdEncoding.EncodeToString(dataKey)}, &response)
+	if err != nil {
+		return "", err
+	}
+	if response.Ciphertext == "" {
+		return "", errors.New("vault returned no ciphertext")
+	}
+	return response.Ciphertext, nil
+}
+
+func (p *VaultKeyProvider) UnwrapKey(ctx context.Context, wrapped string) ([]byte, error) {
+	var response struct {
+		Plaintext string `json:"plaintext"`
+	}
+	if err := p.transit(ctx, "decrypt", map[string]string{"ciphertext": wrapped}, &response); err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(response.Plaintext)
+}
+
+// KeyID returns the transit key version of a wrapped key,
+// "vault:v<version>:<ciphertext>".
+func (p *VaultKeyProvider) KeyID(wrapped string) string {
+	parts := strings.SplitN(wrapped, ":", 3)
+	if len(parts) != 3 || parts[0] != "vault" {
+		return ""
+	}
+	return parts[1]
+}
+
+// transit calls POST /v1/<mount>/<operation>/<key> and decodes the "data"
+// field of the response into result.
+func (p *VaultKeyProvider) transit(ctx context.Context, operation string, body interface{}, result interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	endpoint := fmt.Sprintf("%s/v1/%s/%s/%s", p.config.Address, p.config.Mount, operation, url.PathEscape(p.config.Key))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Vault-Token", p.config.Token)
+	if p.config.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", p.config.Namespace)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var envelope struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []string        `json:"errors"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&envelope); err != nil {
+		return fmt.Errorf("vault %s: status %d: %v", operation, resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("vault %s: status %d: %s", operation, resp.StatusCode, strings.Join(envelope.Errors, "; "))
+	}
+	return json.Unmarshal(envelope.Data, result)
+}
@@ -0,0 +1,195 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// fakeTransit imitates the encrypt and decrypt endpoints of a Vault transit
+// key, remembering what it encrypted instead of doing any cryptography.
+type fakeTransit struct {
+	mu          sync.Mutex
+	version     int
+	ciphertexts map[string]string
+	namespaces  []string
+}
+
+func newFakeTransit(t *testing.T, mount string, key string) (*fakeTransit, *httptest.Server) {
+	t.Helper()
+	fake := &fakeTransit{version: 1, ciphertexts: map[string]string{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/"+mount+"/encrypt/"+key, fake.encrypt)
+	mux.HandleFunc("POST /v1/"+mount+"/decrypt/"+key, fake.decrypt)
+	server := httptest.NewServer(fake.authenticate(mux))
+	t.Cleanup(server.Close)
+	return fake, server
+}
+
+func (f *fakeTransit) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "test-token" {
+			writeVault(w, http.StatusForbidden, nil, "permission denied")
+			return
+		}
+		f.mu.Lock()
+		f.namespaces = append(f.namespaces, r.Header.Get("X-Vault-Namespace"))
+		f.mu.Unlock()
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (f *fakeTransit) encrypt(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Plaintext string `json:"plaintext"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Plaintext == "" {
+		writeVault(w, http.StatusBadRequest, nil, "missing plaintext")
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ciphertext := fmt.Sprintf("vault:v%d:%d", f.version, len(f.ciphertexts))
+	f.ciphertexts[ciphertext] = body.Plaintext
+	writeVault(w, http.StatusOK, map[string]string{"ciphertext": ciphertext}, "")
+}
+
+func (f *fakeTransit) decrypt(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Ciphertext string `json:"ciphertext"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeVault(w, http.StatusBadRequest, nil, "invalid request")
+		return
+	}
+	f.mu.Lock()
+	plaintext, ok := f.ciphertexts[body.Ciphertext]
+	f.mu.Unlock()
+	if !ok {
+		writeVault(w, http.StatusBadRequest, nil, "invalid ciphertext: unable to decrypt")
+		return
+	}
+	writeVault(w, http.StatusOK, map[string]string{"plaintext": plaintext}, "")
+}
+
+func (f *fakeTransit) rotate() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.version++
+}
+
+func writeVault(w http.ResponseWriter, status int, data interface{}, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	response := map[string]interface{}{"data": data}
+	if message != "" {
+		response = map[string]interface{}{"errors": []string{message}}
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+func mustVaultProvider(t *testing.T, config VaultConfig) *VaultKeyProvider {
+	t.Helper()
+	provider, err := NewVaultKeyProvider(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return provider
+}
+
+func TestVaultWrapUnwrapRoundTrip(t *testing.T) {
+	fake, server := newFakeTransit(t, "secrets/transit", "payloads")
+	provider := mustVaultProvider(t, VaultConfig{
+		Address:   server.URL + "/",
+		Token:     "test-token",
+		Namespace: "team-a",
+		Mount:     "secrets/transit",
+		Key:       "payloads",
+	})
+	ctx := context.Background()
+	dataKey := []byte(testKeyA)
+
+	wrapped, err := provider.WrapKey(ctx, dataKey)
+	if err != nil {
+		t.Fatalf("WrapKey: %v", err)
+	}
+	if provider.KeyID(wrapped) != "v1" {
+		t.Errorf("KeyID(%q) = %q, want v1", wrapped, provider.KeyID(wrapped))
+	}
+	unwrapped, err := provider.UnwrapKey(ctx, wrapped)
+	if err != nil {
+		t.Fatalf("UnwrapKey: %v", err)
+	}
+	if !bytes.Equal(unwrapped, dataKey) {
+		t.Errorf("UnwrapKey = %q, want %q", unwrapped, dataKey)
+	}
+
+	// Keys wrapped before a rotation still unwrap
+	fake.rotate()
+	rewrapped, err := provider.WrapKey(ctx, dataKey)
+	if err != nil {
+		t.Fatalf("WrapKey after rotation: %v", err)
+	}
+	if provider.KeyID(rewrapped) != "v2" {
+		t.Errorf("KeyID after rotation = %q, want v2", provider.KeyID(rewrapped))
+	}
+	if _, err := provider.UnwrapKey(ctx, wrapped); err != nil {
+		t.Errorf("UnwrapKey of a key wrapped before rotation: %v", err)
+	}
+
+	for _, namespace := range fake.namespaces {
+		if namespace != "team-a" {
+			t.Fatalf("request sent with namespace %q, want team-a", namespace)
+		}
+	}
+}
+
+func TestVaultErrors(t *testing.T) {
+	_, server := newFakeTransit(t, "transit", "letitgo")
+	ctx := context.Background()
+
+	denied := mustVaultProvider(t, VaultConfig{Address: server.URL, Token: "wrong", Mount: "transit", Key: "letitgo"})
+	if _, err := denied.WrapKey(ctx, []byte(testKeyA)); err == nil {
+		t.Error("WrapKey succeeded with a wrong token")
+	}
+
+	provider := mustVaultProvider(t, VaultConfig{Address: server.URL, Token: "test-token", Mount: "transit", Key: "letitgo"})
+	if _, err := provider.UnwrapKey(ctx, "vault:v1:unknown"); err == nil {
+		t.Error("UnwrapKey succeeded for a ciphertext vault did not produce")
+	}
+
+	if _, err := NewVaultKeyProvider(VaultConfig{Address: server.URL}); err == nil {
+		t.Error("NewVaultKeyProvider accepted a config without a token")
+	}
+}
+
+func TestEnvelopeEncryptionWithVault(t *testing.T) {
+	fake, server := newFakeTransit(t, "transit", "letitgo")
+	provider := mustVaultProvider(t, VaultConfig{Address: server.URL, Token: "test-token", Mount: "transit", Key: "letitgo"})
+	useKeyring(t, nil, provider)
+
+	stored, err := Encrypt(`{"a":1}`)
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	if KeyID(stored) != "envelope" {
+		t.Fatalf("KeyID = %q, want envelope", KeyID(stored))
+	}
+	decrypted, err := Decrypt(stored)
+	if err != nil || decrypted != `{"a":1}` {
+		t.Fatalf("Decrypt = %q, %v", decrypted, err)
+	}
+
+	// A fresh cipher after rotating the transit key wraps new data keys
+	// under v2, so the stored payload is due for re-encryption
+	fake.rotate()
+	useKeyring(t, nil, provider)
+	if stale, err := NeedsReencryption(stored); err != nil || !stale {
+		t.Fatalf("NeedsReencryption = %v, %v after rotating the transit key, want true", stale, err)
+	}
+}
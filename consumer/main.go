@@ -48,6 +48,7 @@ func main() {
 	// Initialize scheduler only after successful DB connection
 	repository.InitializeSchedulerRepository()
 	repository.InitializeArchiveRepository()
+	repository.InitializeCalendarRepository()
 
 	// Connect to Redis
 	repository.RedisConnect(ctx)
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/Sumit189/letItGo/common/models"
+	"github.com/Sumit189/letItGo/common/repository"
+)
+
+const (
+	seriesLockPollInterval = time.Second
+	seriesLockGracePeriod  = time.Minute
+)
+
+// seriesRun holds the series lock of a run for the duration of its execution.
+type seriesRun struct {
+	ctx      context.Context
+	cancel   context.CancelFunc
+	replaced atomic.Bool
+	release  func()
+}
+
+// startSeriesRun enforces the concurrency policy of a run that belongs to a
+// recurring series: either a due series, before its run is started under
+// runID, or a retry of an execution, whose ID is runID. It returns false when
+// the run must be skipped because the previous run of the series is still
+// executing. Under the replace policy the returned run's context is cancelled
+// as soon as a newer run takes the series over.
+func startSeriesRun(ctx context.Context, schedule models.Scheduler, runID string) (*seriesRun, bool) {
+	runCtx, cancel := context.WithCancel(ctx)
+	run := &seriesRun{ctx: runCtx, cancel: cancel, release: cancel}
+
+	policy := schedule.ConcurrencyPolicy
+	inSeries := schedule.SeriesID != "" || schedule.IsRecurring()
+	if !inSeries || (policy != models.ConcurrencyForbid && policy != models.ConcurrencyReplace) {
+		return run, true
+	}
+
+	seriesKey := schedule.SeriesKey()
+	takeover := policy == models.ConcurrencyReplace
+	acquired, err := repository.AcquireSeriesLock(ctx, seriesKey, runID, seriesLockTTL(schedule), takeover)
+	if err != nil {
+		// Fail open: a Redis outage should not stop schedules from running
+		log.Printf("Error acquiring series lock for schedule ID %s: %v", runID, err)
+		return run, true
+	}
+	if !acquired {
+		cancel()
+		return nil, false
+	}
+
+	done := make(chan struct{})
+	if takeover {
+		go run.watchTakeover(seriesKey, runID, done)
+	}
+
+	run.release = func() {
+		close(done)
+		cancel()
+		if err := repository.ReleaseSeriesLock(context.Background(), seriesKey, runID); err != nil {
+			log.Printf("Error releasing series lock for schedule ID %s: %v", runID, err)
+		}
+	}
+	return run, true
+}
+
+// watchTakeover cancels the run once another run holds the series lock.
+func (r *seriesRun) watchTakeover(seriesKey string, runID string, done <-chan struct{}) {
+	ticker := time.NewTicker(seriesLockPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			holder, err := repository.SeriesLockHolder(context.Background(), seriesKey)
+			if err != nil || holder == "" || holder == runID {
+				continue
+			}
+			log.Printf("Schedule ID %s replaced by newer run %s", runID, holder)
+			r.replaced.Store(true)
+			r.cancel()
+			return
+		}
+	}
+}
+
+// seriesLockTTL bounds how long a run can hold its series lock: every webhook
+// attempt plus the sleeps between them, with some grace.
+func seriesLockTTL(schedule models.Scheduler) time.Duration {
+	attempts := time.Duration(schedule.WebhookRetryLimit + 1)
+	perAttempt := sharedClient.Timeout + time.Duration(schedule.RetryAfterInSeconds)*time.Second
+	return attempts*perAttempt + seriesLockGracePeriod
+}
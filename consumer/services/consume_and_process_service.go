@@ -5,10 +5,13 @@ import (
 	"context"
 	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"reflect"
+	"sort"
 	"sync"
 	"syscall"
 	"time"
@@ -35,12 +38,98 @@ const (
 	cacheWindow             = 5 * time.Minute
 )
 
-// ScheduleHeap is a min-heap based on NextRunTime
+// priorityTiers lists the priority levels from the most to the least urgent.
+// Each tier has its own process channel.
+var priorityTiers = []int{models.PriorityCritical, models.PriorityHigh, models.PriorityNormal, models.PriorityLow}
+
+// ScheduleHeap is a min-heap based on NextRunTime, with higher priorities
+// first among schedules due at the same time
 type ScheduleHeap []models.Scheduler
 
-func (h ScheduleHeap) Len() int           { return len(h) }
-func (h ScheduleHeap) Less(i, j int) bool { return h[i].NextRunTime.Before(*h[j].NextRunTime) }
-func (h ScheduleHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h ScheduleHeap) Len() int { return len(h) }
+func (h ScheduleHeap) Less(i, j int) bool {
+	if h[i].NextRunTime.Equal(*h[j].NextRunTime) {
+		return h[i].Priority > h[j].Priority
+	}
+	return h[i].NextRunTime.Before(*h[j].NextRunTime)
+}
+func (h ScheduleHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+// processQueues holds one process channel per priority tier, in the order of
+// priorityTiers.
+type processQueues []chan models.Scheduler
+
+func newProcessQueues() processQueues {
+	queues := make(processQueues, len(priorityTiers))
+	for i := range queues {
+		queues[i] = make(chan models.Scheduler, processChanBuffer)
+	}
+	return queues
+}
+
+// forPriority returns the channel of the tier the priority belongs to.
+// Unknown priorities are clamped to the nearest tier.
+func (q processQueues) forPriority(priority int) chan models.Scheduler {
+	for i, tier := range priorityTiers {
+		if priority >= tier {
+			return q[i]
+		}
+	}
+	return q[len(q)-1]
+}
+
+func (q processQueues) close() {
+	for _, queue := range q {
+		close(queue)
+	}
+}
+
+// next returns the most urgent queued schedule, waiting for one if all
+// queues are empty. It returns false once the context is done or every
+// queue is closed and drained.
+func (q processQueues) next(ctx context.Context) (models.Scheduler, bool) {
+	open := make([]chan models.Scheduler, len(q))
+	copy(open, q)
+
+	for {
+		// Take from the highest tier that has a schedule ready
+		remaining := 0
+		for i, queue := range open {
+			if queue == nil {
+				continue
+			}
+			select {
+			case schedule, ok := <-queue:
+				if ok {
+					return schedule, true
+				}
+				open[i] = nil
+				continue
+			default:
+			}
+			remaining++
+		}
+		if remaining == 0 {
+			return models.Scheduler{}, false
+		}
+
+		// Nothing ready: wait on the context and every tier. Closed tiers
+		// keep their case as a nil channel, which never fires
+		cases := make([]reflect.SelectCase, 0, len(open)+1)
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())})
+		for _, queue := range open {
+			cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(queue)})
+		}
+		chosen, value, ok := reflect.Select(cases)
+		if chosen == 0 {
+			return models.Scheduler{}, false
+		}
+		if ok {
+			return value.Interface().(models.Scheduler), true
+		}
+		open[chosen-1] = nil
+	}
+}
 
 func (h *ScheduleHeap) Push(x interface{}) {
 	*h = append(*h, x.(models.Scheduler))
@@ -124,14 +213,14 @@ func ConsumeAndProcess(ctx context.Context) {
 	var heapMutex sync.Mutex
 	heapCond := sync.NewCond(&heapMutex)
 
-	// Channel to send due schedules for processing
-	processChan := make(chan models.Scheduler, processChanBuffer)
+	// Channels to send due schedules for processing, one per priority tier
+	processChans := newProcessQueues()
 
 	// Start the scheduler goroutine
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		scheduler(ctx, scheduleHeap, &heapMutex, heapCond, processChan)
+		scheduler(ctx, scheduleHeap, &heapMutex, heapCond, processChans)
 	}()
 
 	// Start consumer goroutines
@@ -148,7 +237,7 @@ func ConsumeAndProcess(ctx context.Context) {
 		wg.Add(1)
 		go func(workerID int) {
 			defer wg.Done()
-			processWorker(ctx, processChan, workerID)
+			processWorker(ctx, processChans, workerID)
 		}(i)
 	}
 
@@ -168,8 +257,8 @@ func ConsumeAndProcess(ctx context.Context) {
 	heapCond.Broadcast()
 	heapMutex.Unlock()
 
-	// Close the process channel to signal processors to exit after draining
-	close(processChan)
+	// Close the process channels to signal processors to exit after draining
+	processChans.close()
 
 	// Wait for all goroutines to finish or timeout
 	done := make(chan struct{})
@@ -200,7 +289,7 @@ func consume(ctx context.Context, consumer sarama.ConsumerGroup, workerID int, s
 			log.Printf("Worker %d: Context canceled. Exiting consume.", workerID)
 			return
 		default:
-			if err := consumer.Consume(ctx, []string{kafkaTopic}, handler); err != nil {
+			if err := consumer.Consume(ctx, consumeTopics(), handler); err != nil {
 				log.Printf("Worker %d: Error consuming messages: %v", workerID, err)
 				time.Sleep(time.Second) // Backoff on error
 			}
@@ -208,6 +297,20 @@ func consume(ctx context.Context, consumer sarama.ConsumerGroup, workerID int, s
 	}
 }
 
+// consumeTopics lists the topics to consume. With KAFKA_PRIORITY_TOPICS=true
+// the producer publishes priorities other than normal to their own topics.
+func consumeTopics() []string {
+	topics := []string{kafkaTopic}
+	if os.Getenv("KAFKA_PRIORITY_TOPICS") == "true" {
+		for _, tier := range priorityTiers {
+			if tier != models.PriorityNormal {
+				topics = append(topics, kafkaTopic+"_"+models.Scheduler{Priority: tier}.PriorityName())
+			}
+		}
+	}
+	return topics
+}
+
 // consumerGroupHandler implements sarama.ConsumerGroupHandler
 type consumerGroupHandler struct {
 	ctx          context.Context
@@ -244,7 +347,7 @@ func (h *consumerGroupHandler) ConsumeClaim(
 }
 
 // scheduler manages the schedule heap and dispatches due schedules for processing
-func scheduler(ctx context.Context, scheduleHeap *ScheduleHeap, heapMutex *sync.Mutex, heapCond *sync.Cond, processChan chan<- models.Scheduler) {
+func scheduler(ctx context.Context, scheduleHeap *ScheduleHeap, heapMutex *sync.Mutex, heapCond *sync.Cond, processChans processQueues) {
 	for {
 		heapMutex.Lock()
 		for scheduleHeap.Len() == 0 {
@@ -272,10 +375,13 @@ func scheduler(ctx context.Context, scheduleHeap *ScheduleHeap, heapMutex *sync.
 			}
 			heapMutex.Unlock()
 
-			// Dispatch due schedules
+			// Dispatch due schedules, most urgent first
+			sort.SliceStable(dueSchedules, func(i, j int) bool {
+				return dueSchedules[i].Priority > dueSchedules[j].Priority
+			})
 			for _, schedule := range dueSchedules {
 				select {
-				case processChan <- schedule:
+				case processChans.forPriority(schedule.Priority) <- schedule:
 				default:
 					log.Printf("Process channel is full. Dropping schedule ID %s", schedule.ID)
 				}
@@ -296,28 +402,32 @@ func scheduler(ctx context.Context, scheduleHeap *ScheduleHeap, heapMutex *sync.
 	}
 }
 
-// processWorker listens to the processChan and processes schedules
-func processWorker(ctx context.Context, processChan <-chan models.Scheduler, workerID int) {
+// processWorker takes schedules from the process channels, most urgent tier
+// first, and processes them
+func processWorker(ctx context.Context, processChans processQueues, workerID int) {
 	const maxWorkers = 100
 	workerPool := make(chan struct{}, maxWorkers)
 
 	for {
+		// Wait for a free slot before choosing the next schedule, so a backlog
+		// is always drained in priority order
 		select {
 		case <-ctx.Done():
 			log.Printf("Worker %d: Context canceled. Exiting processWorker.", workerID)
 			return
-		case schedule, ok := <-processChan:
-			if !ok {
-				log.Printf("Worker %d: Process channel closed. Exiting processWorker.", workerID)
-				return
-			}
+		case workerPool <- struct{}{}:
+		}
 
-			workerPool <- struct{}{}
-			go func(schedule models.Scheduler) {
-				defer func() { <-workerPool }()
-				processSchedule(schedule, workerID)
-			}(schedule)
+		schedule, ok := processChans.next(ctx)
+		if !ok {
+			log.Printf("Worker %d: Process channels closed or context canceled. Exiting processWorker.", workerID)
+			return
 		}
+
+		go func(schedule models.Scheduler) {
+			defer func() { <-workerPool }()
+			processSchedule(schedule, workerID)
+		}(schedule)
 	}
 }
 
@@ -344,20 +454,70 @@ func processSchedule(schedule models.Scheduler, workerID int) {
 		return
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	// Enforce the series concurrency policy before a run is started, so a
+	// skipped run of a series neither creates an execution nor counts toward
+	// max_runs
+	runID := fetchedSchedule.ID
+	if fetchedSchedule.IsRecurring() {
+		runID = primitive.NewObjectID().Hex()
+	}
+	run, ok := startSeriesRun(context.Background(), fetchedSchedule, runID)
+	if !ok {
+		log.Printf("Worker %d: Previous run of series %s is still executing, skipping schedule ID %s", workerID, fetchedSchedule.SeriesKey(), fetchedSchedule.ID)
+		if fetchedSchedule.IsRecurring() {
+			err = repository.SkipRun(context.Background(), fetchedSchedule, "previous run still executing")
+		} else {
+			err = repository.SendToArchive(context.Background(), fetchedSchedule, "skipped")
+		}
+		if err != nil {
+			log.Printf("Worker %d: Error skipping schedule ID %s: %v", workerID, fetchedSchedule.ID, err)
+		}
+		return
+	}
+	defer run.release()
+	ctx := run.ctx
+
+	// A recurring series moves on to its next run in place; this run is
+	// executed as a child of the series
+	if fetchedSchedule.IsRecurring() {
+		execution, err := repository.StartRun(context.Background(), fetchedSchedule, runID)
+		if err != nil {
+			log.Printf("Worker %d: Error starting run of series ID %s: %v", workerID, schedule.ID, err)
+			return
+		}
+		fetchedSchedule = execution
+	}
+
+	// A run that can no longer start within its max_lateness is dropped
+	expired, err := repository.ExpireIfLate(context.Background(), fetchedSchedule, time.Now())
+	if err != nil {
+		log.Printf("Worker %d: Error expiring schedule ID %s: %v", workerID, fetchedSchedule.ID, err)
+	}
+	if expired {
+		return
+	}
 
 	go func() {
 		// Mark status in-progress
 		err := repository.UpdateSchedulerStatus(ctx, fetchedSchedule, "in-progress")
 		if err != nil {
-			log.Printf("Worker %d: Error updating status for schedule ID %s: %v", workerID, schedule.ID, err)
+			log.Printf("Worker %d: Error updating status for schedule ID %s: %v", workerID, fetchedSchedule.ID, err)
 		}
 	}()
 
 	// Execute the webhook with context
 	if err := executeWebhook(ctx, fetchedSchedule); err != nil {
 		log.Printf("Worker %d: Error executing webhook for schedule ID %s: %v", workerID, fetchedSchedule.ID, err)
+		if run.replaced.Load() {
+			if err := repository.SendToArchive(context.Background(), fetchedSchedule, "cancelled"); err != nil {
+				log.Printf("Worker %d: Error archiving replaced schedule ID %s: %v", workerID, fetchedSchedule.ID, err)
+			}
+		} else if errors.Is(err, repository.ErrRunCancelled) {
+			fetchedSchedule.StatusReason = repository.SeriesCancelledReason
+			if err := repository.SendToArchive(context.Background(), fetchedSchedule, "cancelled"); err != nil {
+				log.Printf("Worker %d: Error archiving cancelled schedule ID %s: %v", workerID, fetchedSchedule.ID, err)
+			}
+		}
 	} else {
 		markProcessed(ctx, fetchedSchedule)
 	}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Sumit189/letItGo/common/models"
+)
+
+// smallQueues returns process queues with room for a few schedules per tier.
+func smallQueues() processQueues {
+	queues := make(processQueues, len(priorityTiers))
+	for i := range queues {
+		queues[i] = make(chan models.Scheduler, 10)
+	}
+	return queues
+}
+
+func TestProcessQueuesDrainHigherTiersFirst(t *testing.T) {
+	queues := smallQueues()
+	// Queued from the least to the most urgent, two per tier
+	for i := len(priorityTiers) - 1; i >= 0; i-- {
+		priority := priorityTiers[i]
+		for n := 0; n < 2; n++ {
+			queues.forPriority(priority) <- models.Scheduler{Priority: priority}
+		}
+	}
+	queues.close()
+
+	var got []int
+	for {
+		schedule, ok := queues.next(context.Background())
+		if !ok {
+			break
+		}
+		got = append(got, schedule.Priority)
+	}
+	want := []int{
+		models.PriorityCritical, models.PriorityCritical,
+		models.PriorityHigh, models.PriorityHigh,
+		models.PriorityNormal, models.PriorityNormal,
+		models.PriorityLow, models.PriorityLow,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("next() returned priorities %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("next() returned priorities %v, want %v", got, want)
+		}
+	}
+}
+
+func TestProcessQueuesWait(t *testing.T) {
+	t.Run("wakes up for any tier", func(t *testing.T) {
+		for _, priority := range priorityTiers {
+			queues := smallQueues()
+			go func() {
+				time.Sleep(10 * time.Millisecond)
+				queues.forPriority(priority) <- models.Scheduler{Priority: priority}
+			}()
+			schedule, ok := queues.next(context.Background())
+			if !ok || schedule.Priority != priority {
+				t.Errorf("next() = %d, %v, want %d", schedule.Priority, ok, priority)
+			}
+		}
+	})
+
+	t.Run("keeps waiting on open tiers after one closes", func(t *testing.T) {
+		queues := smallQueues()
+		go func() {
+			close(queues[0])
+			time.Sleep(10 * time.Millisecond)
+			queues.forPriority(models.PriorityLow) <- models.Scheduler{Priority: models.PriorityLow}
+		}()
+		schedule, ok := queues.next(context.Background())
+		if !ok || schedule.Priority != models.PriorityLow {
+			t.Errorf("next() = %d, %v, want %d", schedule.Priority, ok, models.PriorityLow)
+		}
+	})
+
+	t.Run("stops when the context is done", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		if _, ok := smallQueues().next(ctx); ok {
+			t.Error("next() returned a schedule from empty queues")
+		}
+	})
+}
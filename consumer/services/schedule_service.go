@@ -30,11 +30,17 @@ var (
 
 func Schedule(ctx context.Context, scheduler models.Scheduler) (models.Scheduler, error) {
 	// Validation checks
-	if scheduler.ScheduleTime == nil && scheduler.CronExpression == "" {
-		return models.Scheduler{}, errors.New("either schedule_time or cron_expression must be provided")
+	if scheduler.ScheduleTime == nil && !scheduler.IsRecurring() {
+		return models.Scheduler{}, errors.New("either schedule_time, cron_expression, rrule or triggers must be provided")
 	}
-	if scheduler.ScheduleTime != nil && scheduler.CronExpression != "" {
-		return models.Scheduler{}, errors.New("schedule_time and cron_expression cannot both be set")
+	if scheduler.ScheduleTime != nil && scheduler.IsRecurring() {
+		return models.Scheduler{}, errors.New("schedule_time cannot be combined with cron_expression, rrule or triggers")
+	}
+	if scheduler.CronExpression != "" && scheduler.RRule != "" {
+		return models.Scheduler{}, errors.New("cron_expression and rrule cannot both be set")
+	}
+	if len(scheduler.Triggers) > 0 && (scheduler.CronExpression != "" || scheduler.RRule != "") {
+		return models.Scheduler{}, errors.New("triggers cannot be combined with cron_expression or rrule")
 	}
 
 	// Encrypt the payload
@@ -90,6 +96,15 @@ func executeWebhook(ctx context.Context, schedule models.Scheduler) error {
 			// Continue processing
 		}
 
+		// Stop retrying once the run is later than its max_lateness allows
+		expired, err := repository.ExpireIfLate(ctx, schedule, time.Now())
+		if err != nil {
+			log.Printf("Error expiring schedule ID %s: %v", schedule.ID, err)
+		}
+		if expired {
+			return repository.ErrRunExpired
+		}
+
 		payloadBytes, err := utils.DecryptAndConvertToJSON(schedule.Payload)
 		if err != nil {
 			log.Printf("Error decrypting payload: %v", err)
@@ -157,6 +172,10 @@ func executeWebhook(ctx context.Context, schedule models.Scheduler) error {
 			log.Printf("Error incrementing and fetching updated schedule: %v", err)
 			return err
 		}
+		if schedule.CancelRequested {
+			log.Printf("Series of schedule ID %s was cancelled, not retrying", schedule.ID)
+			return repository.ErrRunCancelled
+		}
 
 		log.Printf("Retry attempt %d for schedule ID %s", schedule.WebhookRetryCount, schedule.ID)
 	}
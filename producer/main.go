@@ -44,6 +44,8 @@ func main() {
 
 	// Initialize scheduler and connect to Redis
 	repository.InitializeSchedulerRepository()
+	repository.InitializeArchiveRepository()
+	repository.InitializeCalendarRepository()
 	repository.RedisConnect(ctx)
 
 	wg := &sync.WaitGroup{}
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/IBM/sarama"
+	"github.com/Sumit189/letItGo/common/models"
 	"github.com/Sumit189/letItGo/common/repository"
 	"github.com/aws/aws-msk-iam-sasl-signer-go/signer"
 )
@@ -113,6 +114,16 @@ func PollAndProduce(ctx context.Context) {
 	}
 }
 
+// topicFor returns the Kafka topic a schedule is published to. With
+// KAFKA_PRIORITY_TOPICS=true, priorities other than normal get their own topic
+// so a backlog of normal schedules cannot hold them up.
+func topicFor(schedule models.Scheduler) string {
+	if os.Getenv("KAFKA_PRIORITY_TOPICS") != "true" || schedule.Priority == models.PriorityNormal {
+		return kafkaTopic
+	}
+	return kafkaTopic + "_" + schedule.PriorityName()
+}
+
 func publishDueSchedules(ctx context.Context, producer sarama.AsyncProducer) error {
 	schedules, err := FetchPendingSchedules(ctx, int64(maxFetchPerWin))
 	if err != nil {
@@ -131,7 +142,7 @@ func publishDueSchedules(ctx context.Context, producer sarama.AsyncProducer) err
 		}
 
 		msg := &sarama.ProducerMessage{
-			Topic: kafkaTopic,
+			Topic: topicFor(schedule),
 			Key:   sarama.StringEncoder(schedule.ID),
 			Value: sarama.ByteEncoder(bytes),
 		}